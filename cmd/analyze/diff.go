@@ -0,0 +1,113 @@
+package analyze
+
+import (
+	"context"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/internal/config"
+	"github.com/bthuilot/dockerleaks/pkg/analysis"
+	"github.com/bthuilot/dockerleaks/pkg/image"
+	"github.com/bthuilot/dockerleaks/pkg/logging"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DiffCommand compares the secrets of two images
+var DiffCommand = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the secrets of two images",
+	Long: `Scan two images with the static and dynamic analysis and compare their findings,
+reporting the secrets added, removed and unchanged by the newer image.
+Only secrets added by the newer image fail the scan.
+
+Images are given as a name, read from the docker daemon or registry (--registry),
+'archive:path' for an archive created by docker save, or 'oci:path[:tag]' for an OCI image layout`,
+	Example: `  dockerleaks diff --old my-image:v1 --new my-image:v2
+  dockerleaks diff --old archive:v1.tar --new archive:v2.tar -o sarif=diff.sarif`,
+	Run: func(cmd *cobra.Command, args []string) {
+		oldRef, _ := cmd.Flags().GetString("old")
+		newRef, _ := cmd.Flags().GetString("new")
+		if oldRef == "" || newRef == "" {
+			logging.Fatal("both flags `old` and `new` must be set\n")
+		}
+
+		ctx := context.WithValue(context.Background(), startTimeContextKey, time.Now())
+		ctx = context.WithValue(ctx, sourcesContextKey, diffSources)
+		ctx = parseReportFlags(ctx, cmd)
+		cfg, staticRules, dynamicRules := loadRules(cmd)
+
+		oldImg, oldDetector := openDiffImage(cmd, oldRef, cfg, staticRules, dynamicRules)
+		oldCache := layerCache(cmd, oldDetector)
+		oldFindings := scanImage(ctx, cmd, oldImg, oldDetector, oldCache)
+		oldInfo := scanInfo(ctx, oldImg, oldDetector, oldCache)
+		if err := oldImg.Close(); err != nil {
+			logrus.Errorf("error closing image: %s", err)
+		}
+
+		newImg, newDetector := openDiffImage(cmd, newRef, cfg, staticRules, dynamicRules)
+		newCache := layerCache(cmd, newDetector)
+		newFindings := scanImage(ctx, cmd, newImg, newDetector, newCache)
+		info := scanInfo(ctx, newImg, newDetector, newCache)
+		if err := newImg.Close(); err != nil {
+			logrus.Errorf("error closing image: %s", err)
+		}
+
+		tagFindings(oldFindings, oldInfo)
+		tagFindings(newFindings, info)
+		info.BaseImage, info.BaseDigest = oldInfo.Image, oldInfo.Digest
+		if report(ctx, cmd, analysis.Diff(oldFindings, newFindings), info) {
+			os.Exit(logging.ExitFindings)
+		}
+	},
+}
+
+// diffSources are the sources searched in each image compared
+var diffSources = append(append([]analysis.Source{}, analysis.StaticSources...), analysis.FileSources...)
+
+func init() {
+	DiffCommand.Flags().String("old", "", "the older image, whose findings are compared against")
+	DiffCommand.Flags().String("new", "", "the newer image, whose findings are reported")
+	DiffCommand.Flags().Int("workers", runtime.GOMAXPROCS(0), "amount of files searched concurrently")
+	addRegistryFlags(DiffCommand.Flags())
+	addReportFlags(DiffCommand.Flags())
+	addScanFlags(DiffCommand.Flags())
+}
+
+// openDiffImage will open the image referenced by ref, and create its detector
+func openDiffImage(cmd *cobra.Command, ref string, cfg config.File, staticRules []secrets.StaticRule, dynamicRules []secrets.DynamicRule) (image.Image, secrets.Detector) {
+	var img image.Image
+	switch {
+	case strings.HasPrefix(ref, "archive:"):
+		img = openImage(cmd, "", strings.TrimPrefix(ref, "archive:"), "")
+	case strings.HasPrefix(ref, "oci:"):
+		img = openImage(cmd, "", "", strings.TrimPrefix(ref, "oci:"))
+	default:
+		img = openImage(cmd, ref, "", "")
+	}
+	detector, err := newDetector(cmd, cfg, staticRules, dynamicRules, img.Name())
+	if err != nil {
+		logging.Fatal("%s\n", err)
+	}
+	return img, detector
+}
+
+// scanImage will search the image with the static and dynamic analysis,
+// reusing the findings of cached layers, exiting if either fails
+func scanImage(ctx context.Context, cmd *cobra.Command, img image.Image, detector secrets.Detector, layerCache *analysis.LayerCache) []analysis.Finding {
+	spnr := logging.StartSpinner(fmt.Sprintf("analyzing %s...", img.Name()))
+	findings, err := analysis.Static(img, detector)
+	if err == nil {
+		var fileFindings []analysis.Finding
+		opts := analysis.DynamicOpts{Container: image.DefaultContainerOpts}
+		opts.Workers, _ = cmd.Flags().GetInt("workers")
+		opts.Cache = layerCache
+		fileFindings, err = analysis.Dynamic(ctx, img, detector, opts)
+		findings = append(findings, fileFindings...)
+	}
+	logging.FinishSpinnerWithError(spnr, err) // Exit if error
+	return findings
+}
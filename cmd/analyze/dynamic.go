@@ -3,24 +3,30 @@ package analyze
 import (
 	"context"
 	"github.com/bthuilot/dockerleaks/pkg/analysis"
-	"github.com/bthuilot/dockerleaks/pkg/logging"
 	"github.com/spf13/cobra"
+	"runtime"
 )
 
 var dynamic = &cobra.Command{
 	Use:   "dynamic",
 	Short: "Analyze an image for secrets dynamically",
-	Long:  `Analyze a built docker image by starting the container and inspecting file contents`,
+	Long: `Analyze a built docker image by creating a container and inspecting file contents.
+The container is not started unless --run is given, in which case the entrypoint of the
+image is run inside a sandbox without network access or capabilities and a read-only root filesystem`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := cmd.Context()
-		img, detector := parseContext(ctx) // will exit if error
-
-		spnr := logging.StartSpinner("beginning dynamic analysis...")
-
-		findings, err := analysis.Dynamic(img, detector)
-		logging.FinishSpinnerWithError(spnr, err) // Exit if error
-
-		ctx = context.WithValue(ctx, findingsContextKey, findings)
-		cmd.SetContext(ctx)
+		opts := analysis.DynamicOpts{Container: containerOpts(cmd)}
+		opts.Container.Start, _ = cmd.Flags().GetBool("run")
+		opts.Workers, _ = cmd.Flags().GetInt("workers")
+		scanImages(cmd, analysis.FileSources, func(ctx context.Context, s imageScan) ([]analysis.Finding, error) {
+			imageOpts := opts
+			imageOpts.Cache = s.cache
+			return analysis.Dynamic(ctx, s.img, s.detector, imageOpts)
+		})
 	},
 }
+
+func init() {
+	dynamic.Flags().Bool("run", false, "start the container in a sandbox before inspecting its filesystem")
+	dynamic.Flags().Int("workers", runtime.GOMAXPROCS(0), "amount of files searched concurrently")
+	addContainerFlags(dynamic)
+}
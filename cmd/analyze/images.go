@@ -0,0 +1,270 @@
+package analyze
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/analysis"
+	"github.com/bthuilot/dockerleaks/pkg/image"
+	"github.com/bthuilot/dockerleaks/pkg/logging"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// imageScan is an image opened for a scan, with the detector and layer cache it is searched with
+type imageScan struct {
+	img      image.Image
+	detector secrets.Detector
+	cache    *analysis.LayerCache
+}
+
+// analyzer searches an image opened for a scan, returning the findings of the image
+type analyzer func(ctx context.Context, s imageScan) ([]analysis.Finding, error)
+
+// imageResult is the result of the scan of an image
+type imageResult struct {
+	ref      string
+	findings []analysis.Finding
+	info     analysis.ScanInfo
+	err      error
+}
+
+// imageOpener opens the images of a scan, sharing a single
+// connection to the docker daemon between the images read from it
+type imageOpener struct {
+	cmd         *cobra.Command
+	archivePath string
+	ociDir      string
+
+	// once guards the connection to the daemon, made when the first image is read from it
+	once      sync.Once
+	daemon    image.Daemon
+	daemonErr error
+}
+
+// newImageOpener will create an imageOpener for images read from the OCI layout ociDir,
+// the archive at archivePath, or the registry or docker daemon, in that order of precedence
+func newImageOpener(cmd *cobra.Command, archivePath, ociDir string) *imageOpener {
+	return &imageOpener{cmd: cmd, archivePath: archivePath, ociDir: ociDir}
+}
+
+// open will open the image with the given name, or tag of the archive
+func (o *imageOpener) open(name string) (image.Image, error) {
+	switch {
+	case o.ociDir != "":
+		// Read the image from an OCI image layout
+		dir, tag := image.SplitOCIDir(o.ociDir)
+		return image.NewOCIImage(dir, tag)
+	case o.archivePath != "":
+		// Read the image from a `docker save` archive
+		return image.NewArchiveImage(o.archivePath, name)
+	case useRegistry(o.cmd):
+		// Read the image directly from its registry
+		return image.NewRegistryImage(name, registryOpts(o.cmd))
+	default:
+		// Connect to docker daemon once for every image
+		o.once.Do(func() {
+			o.daemon, o.daemonErr = image.NewDaemon()
+		})
+		if o.daemonErr != nil {
+			return nil, o.daemonErr
+		}
+		return o.daemon.Image(name)
+	}
+}
+
+// imageRefs will parse the images to scan from the `image` and `images-file` flags,
+// without duplicates. The program exits if the images file cannot be read
+func imageRefs(cmd *cobra.Command) []string {
+	refs, _ := cmd.Flags().GetStringArray("image")
+	if path, _ := cmd.Flags().GetString("images-file"); path != "" {
+		var (
+			fileRefs []string
+			err      error
+		)
+		if path == "-" {
+			fileRefs, err = readImageRefs(os.Stdin)
+		} else {
+			var f *os.File
+			if f, err = os.Open(path); err == nil {
+				fileRefs, err = readImageRefs(f)
+				_ = f.Close()
+			}
+		}
+		if err != nil {
+			logging.Fatal("unable to read images file: %s\n", err)
+		}
+		refs = append(refs, fileRefs...)
+	}
+
+	seen := make(map[string]bool, len(refs))
+	unique := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if !seen[ref] {
+			seen[ref] = true
+			unique = append(unique, ref)
+		}
+	}
+	return unique
+}
+
+// readImageRefs will read one image per line, ignoring
+// empty lines and comments starting with '#'
+func readImageRefs(r io.Reader) ([]string, error) {
+	var refs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	return refs, scanner.Err()
+}
+
+// scanImages will search each image parsed by the [Command] PersistentPreRun hook with the analyzer,
+// with at most the amount of images set by the `parallel` flag scanned concurrently.
+// The findings of every image and a description of the scan are set in the context of the command
+func scanImages(cmd *cobra.Command, sources []analysis.Source, analyze analyzer) {
+	ctx := context.WithValue(cmd.Context(), sourcesContextKey, sources)
+	refs, _ := ctx.Value(imagesContextKey).([]string)
+	rules, ok := ctx.Value(rulesContextKey).(ruleset)
+	if !ok {
+		logging.Fatal(errorMsgFmt, "error parsing rules context")
+	}
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	if parallel < 1 {
+		parallel = 1
+	}
+	archivePath, _ := cmd.Flags().GetString("archive")
+	ociDir, _ := cmd.Flags().GetString("oci-dir")
+	opener := newImageOpener(cmd, archivePath, ociDir)
+
+	msg := fmt.Sprintf("beginning %s analysis...", cmd.Name())
+	if len(refs) > 1 {
+		msg = fmt.Sprintf("beginning %s analysis of %d images...", cmd.Name(), len(refs))
+	}
+	spnr := logging.StartSpinner(msg)
+
+	var (
+		results = make([]imageResult, len(refs))
+		slots   = make(chan struct{}, parallel)
+		wg      sync.WaitGroup
+	)
+	for i, ref := range refs {
+		wg.Add(1)
+		go func(i int, ref string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = scanRef(ctx, cmd, opener, rules, ref, analyze)
+		}(i, ref)
+	}
+	wg.Wait()
+
+	if len(results) == 1 {
+		logging.FinishSpinnerWithError(spnr, results[0].err) // Exit if error
+	} else {
+		logging.FinishSpinnerWithError(spnr, nil)
+	}
+
+	var (
+		findings []analysis.Finding
+		failed   int
+	)
+	for _, r := range results {
+		if r.err != nil {
+			logging.Msg("unable to scan image %s: %s\n", r.ref, r.err)
+			failed++
+			continue
+		}
+		findings = append(findings, r.findings...)
+	}
+	if failed > 0 {
+		logging.Msg("%d of %d images could not be scanned\n", failed, len(results))
+	}
+
+	ctx = context.WithValue(ctx, findingsContextKey, findings)
+	ctx = context.WithValue(ctx, infoContextKey, aggregateInfo(ctx, results))
+	ctx = context.WithValue(ctx, failedContextKey, failed)
+	cmd.SetContext(ctx)
+}
+
+// scanRef will open the image referenced by ref and search it with the analyzer,
+// tagging each finding with the name and digest of the image
+func scanRef(ctx context.Context, cmd *cobra.Command, opener *imageOpener, rules ruleset, ref string, analyze analyzer) imageResult {
+	result := imageResult{ref: ref}
+	img, err := opener.open(ref)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer func() {
+		if err := img.Close(); err != nil {
+			logrus.Errorf("error closing image: %s", err)
+		}
+	}()
+	result.ref = img.Name()
+
+	if pull, _ := cmd.Flags().GetBool("pull"); pull {
+		logrus.Infof("pulling image %s from remote", img.Name())
+		if result.err = img.Pull(); result.err != nil {
+			return result
+		}
+	}
+
+	detector, err := newDetector(cmd, rules.cfg, rules.static, rules.dynamic, img.Name())
+	if err != nil {
+		result.err = err
+		return result
+	}
+	layerCache := layerCache(cmd, detector)
+
+	logrus.Infof("scanning image %s", img.Name())
+	result.findings, result.err = analyze(ctx, imageScan{img: img, detector: detector, cache: layerCache})
+	if result.err != nil {
+		return result
+	}
+	result.info = scanInfo(ctx, img, detector, layerCache)
+	tagFindings(result.findings, result.info)
+	return result
+}
+
+// tagFindings will set the image and digest of each finding to those of the scanned image
+func tagFindings(findings []analysis.Finding, info analysis.ScanInfo) {
+	for i := range findings {
+		findings[i].Image, findings[i].Digest = info.Image, info.Digest
+	}
+}
+
+// aggregateInfo will describe the scan of every image scanned successfully, as one scan
+func aggregateInfo(ctx context.Context, results []imageResult) analysis.ScanInfo {
+	sources, _ := ctx.Value(sourcesContextKey).([]analysis.Source)
+	start, _ := ctx.Value(startTimeContextKey).(time.Time)
+	info := analysis.ScanInfo{Sources: sources, Time: start}
+
+	var names []string
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		names = append(names, r.info.Image)
+		info.Digest = r.info.Digest
+		info.Suppressed += r.info.Suppressed
+		if info.Rules == nil {
+			info.Rules = r.info.Rules
+		}
+	}
+	info.Image = strings.Join(names, ", ")
+	if len(names) != 1 {
+		info.Digest = ""
+	}
+	return info
+}
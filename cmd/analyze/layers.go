@@ -0,0 +1,19 @@
+package analyze
+
+import (
+	"context"
+	"github.com/bthuilot/dockerleaks/pkg/analysis"
+	"github.com/spf13/cobra"
+)
+
+var layers = &cobra.Command{
+	Use:   "layers",
+	Short: "Analyze each layer of an image for secrets",
+	Long: `Analyze each layer of a built docker image in order, including files
+that are deleted or overwritten by later layers but still ship with the image`,
+	Run: func(cmd *cobra.Command, args []string) {
+		scanImages(cmd, analysis.FileSources, func(ctx context.Context, s imageScan) ([]analysis.Finding, error) {
+			return analysis.Layers(s.img, s.detector, s.cache)
+		})
+	},
+}
@@ -0,0 +1,221 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/analysis"
+	"github.com/bthuilot/dockerleaks/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// addReportFlags will add the flags configuring the report of the findings of a scan
+func addReportFlags(flags *pflag.FlagSet) {
+	flags.StringArrayP("output", "o", []string{"text"}, fmt.Sprintf(
+		"output format (%s), optionally suffixed with '=path' to write it to a file. may be given multiple times",
+		strings.Join(outputFormats, ", "),
+	))
+	flags.String("report-path", "", "path of the file outputs without a path are written to, instead of stdout")
+	flags.String("template", "", fmt.Sprintf(
+		"path to a go template file used by the template output format, or the name of an example template (%s)",
+		strings.Join(analysis.TemplateNames(), ", "),
+	))
+
+	flags.StringSlice("fail-on", nil, "sources (env-var, build-arg, file, runtime-file, process-env, process-cmdline) and minimum severity (low, medium, high, critical) of findings that fail the scan, exiting with code 1 (default: any finding)")
+
+	flags.String("baseline", "", "path to a JSON report of accepted findings, which are suppressed")
+	flags.String("write-baseline", "", "path to write a JSON report of all findings to, without their secrets, for use with --baseline")
+}
+
+// parseReportFlags will parse the outputs, template, fail policy and baseline of the report
+// into the context, so that invalid flags fail before scanning. The program exits if any are invalid
+func parseReportFlags(ctx context.Context, cmd *cobra.Command) context.Context {
+	outputFlags, _ := cmd.Flags().GetStringArray("output")
+	reportPath, _ := cmd.Flags().GetString("report-path")
+	outputs, err := parseOutputs(outputFlags, reportPath)
+	if err != nil {
+		logging.Fatal("invalid output: %s\n", err)
+	}
+	ctx = context.WithValue(ctx, outputsContextKey, outputs)
+	if hasOutputFormat(outputs, "template") {
+		templatePath, _ := cmd.Flags().GetString("template")
+		tmpl, err := analysis.ParseTemplate(templatePath)
+		if err != nil {
+			logging.Fatal("invalid output template: %s\n", err)
+		}
+		ctx = context.WithValue(ctx, templateContextKey, tmpl)
+	}
+
+	failOn, _ := cmd.Flags().GetStringSlice("fail-on")
+	policy, err := analysis.ParseFailPolicy(failOn)
+	if err != nil {
+		logging.Fatal("invalid fail-on policy: %s\n", err)
+	}
+	ctx = context.WithValue(ctx, policyContextKey, policy)
+
+	if baselinePath, _ := cmd.Flags().GetString("baseline"); baselinePath != "" {
+		baseline, err := analysis.LoadBaseline(baselinePath)
+		if err != nil {
+			logging.Fatal("invalid baseline: %s\n", err)
+		}
+		ctx = context.WithValue(ctx, baselineContextKey, baseline)
+	}
+	return ctx
+}
+
+// report will write the findings of the scan to each output, returning
+// true if any finding fails the scan according to the fail policy
+func report(ctx context.Context, cmd *cobra.Command, findings []analysis.Finding, info analysis.ScanInfo) bool {
+	if info.Suppressed > 0 {
+		logging.Msg("%d secrets found are suppressed by allowlists\n", info.Suppressed)
+	}
+	if baselinePath, _ := cmd.Flags().GetString("write-baseline"); baselinePath != "" {
+		if err := analysis.WriteBaseline(baselinePath, findings); err != nil {
+			logging.Fatal("%s\n", err)
+		}
+		logrus.Infof("wrote baseline of %d findings to %s", len(findings), baselinePath)
+	}
+	if baseline, ok := ctx.Value(baselineContextKey).(analysis.Baseline); ok {
+		var suppressed int
+		findings, suppressed = baseline.Filter(findings)
+		if suppressed > 0 {
+			logging.Msg("%d secrets found are suppressed by the baseline\n", suppressed)
+		}
+	}
+
+	logging.Header(summary(findings), logging.H1)
+	outputs, _ := ctx.Value(outputsContextKey).([]output)
+	for _, o := range outputs {
+		if err := writeOutput(o, formatter(ctx, info, o.format), findings); err != nil {
+			logging.Fatal("%s: %s\n", err, o.format)
+		}
+	}
+
+	policy, _ := ctx.Value(policyContextKey).(analysis.FailPolicy)
+	failing := policy.Failing(findings)
+	if len(failing) > 0 {
+		logging.Msg("%d of %d secrets found fail the scan\n", len(failing), len(findings))
+		return true
+	}
+	if len(findings) > 0 {
+		logging.Msg("no secrets found fail the scan, see --fail-on\n")
+	}
+	return false
+}
+
+// summary will return the header summarizing the findings
+func summary(findings []analysis.Finding) string {
+	if len(findings) == 0 {
+		return "no secret strings found"
+	}
+	counts := make(map[analysis.Change]int)
+	for _, f := range findings {
+		counts[f.Change]++
+	}
+	if counts[""] == len(findings) {
+		return fmt.Sprintf("%d secrets found", len(findings))
+	}
+	return fmt.Sprintf(
+		"%d secrets added, %d removed, %d unchanged",
+		counts[analysis.Added], counts[analysis.Removed], counts[analysis.Unchanged],
+	)
+}
+
+// outputFormats are the names of the supported output formats
+var outputFormats = []string{"text", "json", "sarif", "junit", "html", "template"}
+
+// output is a format the findings are written in, and where they are written to
+type output struct {
+	// format is the name of the output format
+	format string
+	// path is the file the output is written to, or empty for stdout
+	path string
+}
+
+// parseOutputs will parse the values of the `output` flag in the form 'format[=path]'.
+// Outputs without a path are written to reportPath if it is set, of which there may only be one
+func parseOutputs(values []string, reportPath string) ([]output, error) {
+	var (
+		outputs     []output
+		unspecified int
+	)
+	for _, v := range values {
+		format, path, _ := strings.Cut(v, "=")
+		if !validOutputFormat(format) {
+			return nil, fmt.Errorf("invalid output format '%s', must be one of: %s", format, strings.Join(outputFormats, ", "))
+		}
+		if path == "" {
+			unspecified++
+			path = reportPath
+		}
+		outputs = append(outputs, output{format: format, path: path})
+	}
+	if reportPath != "" && unspecified > 1 {
+		return nil, errors.New("only one output without a path may be written to the report path")
+	}
+	return outputs, nil
+}
+
+// validOutputFormat will return true if format is the name of a supported output format
+func validOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOutputFormat will return true if any of the outputs is in the format
+func hasOutputFormat(outputs []output, format string) bool {
+	for _, o := range outputs {
+		if o.format == format {
+			return true
+		}
+	}
+	return false
+}
+
+// formatter will return the formatter of the output format
+func formatter(ctx context.Context, info analysis.ScanInfo, format string) analysis.Formatter {
+	switch format {
+	case "json":
+		return analysis.JSONFormatter
+	case "sarif":
+		return analysis.SARIFFormatter(info)
+	case "junit":
+		return analysis.JUnitFormatter(info)
+	case "html":
+		return analysis.HTMLFormatter(info)
+	case "template":
+		tmpl, _ := ctx.Value(templateContextKey).(*template.Template)
+		return analysis.TemplateFormatter(info, tmpl)
+	default:
+		return analysis.DefaultFormatter
+	}
+}
+
+// writeOutput will format the findings and write them to the path of the output, or stdout
+func writeOutput(o output, formatter analysis.Formatter, findings []analysis.Finding) error {
+	formatted, err := formatter(findings)
+	if err != nil {
+		logrus.Errorf("error formatting findings as %s: %s", o.format, err)
+		return errors.New("unable to format findings")
+	}
+	if o.path == "" {
+		fmt.Println(formatted)
+		return nil
+	}
+	// reports may contain unmasked secrets, so they are only readable by the user
+	if err = os.WriteFile(o.path, []byte(formatted+"\n"), 0o600); err != nil {
+		logrus.Errorf("error writing report %s: %s", o.path, err)
+		return errors.New("unable to write report")
+	}
+	logrus.Infof("wrote %s report to %s", o.format, o.path)
+	return nil
+}
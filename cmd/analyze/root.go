@@ -3,23 +3,39 @@ package analyze
 import (
 	"context"
 	"fmt"
-	"github.com/briandowns/spinner"
 	"github.com/bthuilot/dockerleaks/internal/config"
 	"github.com/bthuilot/dockerleaks/pkg/analysis"
+	"github.com/bthuilot/dockerleaks/pkg/cache"
 	"github.com/bthuilot/dockerleaks/pkg/image"
+	"github.com/bthuilot/dockerleaks/pkg/image/registry"
 	"github.com/bthuilot/dockerleaks/pkg/logging"
 	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"github.com/docker/go-units"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
 type contextKey string
 
 const (
-	imageContextKey    contextKey = "dockerleaks-docker-image"
-	detectorContextKey contextKey = "dockerleaks-secret-detector"
-	findingsContextKey contextKey = "dockerleaks-findings"
+	imagesContextKey    contextKey = "dockerleaks-images"
+	rulesContextKey     contextKey = "dockerleaks-rules"
+	findingsContextKey  contextKey = "dockerleaks-findings"
+	infoContextKey      contextKey = "dockerleaks-scan-info"
+	failedContextKey    contextKey = "dockerleaks-failed-images"
+	sourcesContextKey   contextKey = "dockerleaks-sources"
+	startTimeContextKey contextKey = "dockerleaks-start-time"
+	templateContextKey  contextKey = "dockerleaks-template"
+	policyContextKey    contextKey = "dockerleaks-fail-policy"
+	outputsContextKey   contextKey = "dockerleaks-outputs"
+	baselineContextKey  contextKey = "dockerleaks-baseline"
 )
 
 const errorMsgFmt = `!! ERROR: %s !!
@@ -28,59 +44,38 @@ https://github.com/bthuilot/dockerleaks/issues/new/choose`
 
 var Command = &cobra.Command{
 	Use:   "analyze",
-	Short: "Analyze an image for secrets",
-	Long:  `Analyze an image for secrets, either statically or dynamically.`,
+	Short: "Analyze images for secrets",
+	Long: `Analyze images for secrets, either statically or dynamically.
+Multiple images are scanned concurrently and reported together`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		var (
-			cfg  config.File
-			spnr *spinner.Spinner
-			ctx  = context.Background()
-		)
+		ctx := context.Background()
 
-		// Parse image name from CLI argss
-		imageName, _ := cmd.Flags().GetString("image")
-
-		// Parse the configuration file and user supplied rules
-		spnr = logging.StartSpinner("parsing configuration...")
-		err := viper.Unmarshal(&cfg)
-
-		logrus.Infof("parsing regular expression detection configuration")
-		staticRules, invalidStaticRules := secrets.ParseStaticRules(cfg.StaticRules)
-		dynamicRules, invalidDynamicRules := secrets.ParseDynamicRules(cfg.DynamicRules)
-
-		logging.FinishSpinnerWithError(spnr, err)
-		for _, iR := range invalidStaticRules {
-			logrus.Errorf("invalid static rule 'pattern: %s'", iR.Pattern)
-		}
-		for _, iR := range invalidDynamicRules {
-			logrus.Errorf("invalid dynamic rule 'pattern: %s, file: %s'", iR.Pattern, iR.FilePattern)
+		// Parse the images to scan from CLI args
+		imagesFile, _ := cmd.Flags().GetString("images-file")
+		if passwordStdin, _ := cmd.Flags().GetBool("registry-password-stdin"); passwordStdin && imagesFile == "-" {
+			logging.Fatal("flags `registry-password-stdin` and `images-file -` cannot both read stdin\n")
 		}
-		if len(invalidStaticRules) > 0 || len(invalidDynamicRules) > 0 {
-			if !cfg.IgnoreInvalidRules {
-				logging.Fatal("invalid rules found, exiting due to flag `ignore-invalid` not set")
-			}
+		refs := imageRefs(cmd)
+		archivePath, _ := cmd.Flags().GetString("archive")
+		ociDir, _ := cmd.Flags().GetString("oci-dir")
+		switch {
+		case ociDir != "" && len(refs) > 0:
+			logging.Fatal("flag `oci-dir` cannot be combined with `image` or `images-file`, select the image with the ':tag' suffix\n")
+		case ociDir != "" || (archivePath != "" && len(refs) == 0):
+			// the image is selected by the OCI layout, or is the only image of the archive
+			refs = []string{""}
+		case len(refs) == 0:
+			logging.Fatal("one of the flags `image`, `images-file`, `archive` or `oci-dir` must be set\n")
 		}
+		ctx = context.WithValue(ctx, imagesContextKey, refs)
+		ctx = context.WithValue(ctx, startTimeContextKey, time.Now())
 
-		detector := secrets.NewDetector(
-			secrets.Opts{
-				UseDefaultStaticRules:  !cfg.ExcludeDefaultStaticRules,
-				UseDefaultDynamicRules: !cfg.ExcludeDefaultDynamicRules,
-			},
-			staticRules,
-			dynamicRules,
-		)
-		ctx = context.WithValue(ctx, detectorContextKey, detector)
-		// Connect to docker daemon and pull image if necessary
-		spnr = logging.StartSpinner("connecting to docker daemon...")
-		i, err := image.NewImage(imageName)
-		logging.FinishSpinnerWithError(spnr, err)
-
-		if pull, _ := cmd.Flags().GetBool("pull"); pull {
-			spnr = logging.StartSpinner("pulling image from remote")
-			err = i.Pull()
-			logging.FinishSpinnerWithError(spnr, err)
-		}
-		ctx = context.WithValue(ctx, imageContextKey, i)
+		// Parse the outputs before scanning, so invalid outputs fail fast
+		ctx = parseReportFlags(ctx, cmd)
+
+		// Parse the configuration file and user supplied rules
+		cfg, staticRules, dynamicRules := loadRules(cmd)
+		ctx = context.WithValue(ctx, rulesContextKey, ruleset{cfg: cfg, static: staticRules, dynamic: dynamicRules})
 		cmd.SetContext(ctx)
 	},
 
@@ -93,54 +88,295 @@ var Command = &cobra.Command{
 		if !ok {
 			logging.Fatal(errorMsgFmt, "error parsing findings from context")
 		}
+		info, _ := ctx.Value(infoContextKey).(analysis.ScanInfo)
 
-		var formatter analysis.Formatter
-		switch format, _ := cmd.Flags().GetString("output"); format {
-		case "json":
-			formatter = analysis.JSONFormatter
-		default:
-			formatter = analysis.DefaultFormatter
-		}
-
-		if len(findings) == 0 {
-			logging.Header("no secret strings found", logging.H1)
-		} else {
-			logging.Header(fmt.Sprintf("%d secrets found", len(findings)), logging.H1)
+		failing := report(ctx, cmd, findings, info)
+		if failed, _ := ctx.Value(failedContextKey).(int); failed > 0 {
+			os.Exit(logging.ExitError)
 		}
-		output, err := formatter(findings)
-		if err != nil {
-			logrus.Errorf("error formatting findings: %s", err)
-			logging.Fatal(errorMsgFmt, "error formatting findings")
+		if failing {
+			os.Exit(logging.ExitFindings)
 		}
-		fmt.Println(output)
 	},
 }
 
 func init() {
-	Command.PersistentFlags().StringP("image", "i", "", "the name of the image")
-	if err := Command.MarkPersistentFlagRequired("image"); err != nil {
+	Command.PersistentFlags().StringArrayP("image", "i", nil, "the name of an image to scan, may be given multiple times")
+	Command.PersistentFlags().String("images-file", "", "path to a file of images to scan, one per line, or '-' to read them from stdin")
+	Command.PersistentFlags().Int("parallel", 2, "amount of images scanned concurrently")
+	Command.PersistentFlags().StringP("archive", "a", "", "path to an image archive created by docker save")
+	if err := Command.MarkPersistentFlagFilename("archive", "tar"); err != nil {
 		logging.Fatal(err.Error())
 	}
+	Command.PersistentFlags().String("oci-dir", "", "path to an OCI image layout directory, optionally suffixed with ':tag'")
+	Command.MarkFlagsMutuallyExclusive("archive", "oci-dir")
+
+	addRegistryFlags(Command.PersistentFlags())
 
 	Command.PersistentFlags().BoolP("pull", "p", false, "image should be pulled from remote")
 
-	Command.PersistentFlags().StringP("output", "o", "text", "output format (text, json)")
+	addReportFlags(Command.PersistentFlags())
+	addScanFlags(Command.PersistentFlags())
+
+	Command.AddCommand(static, dynamic, layers, runtimeCmd)
+}
+
+// addRegistryFlags will add the flags configuring how images are read from their registry
+func addRegistryFlags(flags *pflag.FlagSet) {
+	flags.Bool("registry", false, "read the image directly from its registry instead of the docker daemon")
+	flags.Bool("insecure-registry", false, "connect to the registry over plain HTTP (implies --registry)")
+	flags.String("registry-username", "", "username to authenticate with the registry")
+	flags.String("registry-password", "", fmt.Sprintf("password or token to authenticate with the registry, prefer --registry-password-stdin or %s", registryPasswordEnv))
+	flags.Bool("registry-password-stdin", false, "read the password or token to authenticate with the registry from stdin")
+}
+
+// addScanFlags will add the flags configuring the rules and detector of a scan
+func addScanFlags(flags *pflag.FlagSet) {
+	flags.String("max-file-size", "0", "size above which the content of a file is handled by --oversized-files (0 for no limit)")
+	flags.String("oversized-files", string(secrets.SkipOversized), "how the content of files larger than --max-file-size is searched (skip, scan the first --max-file-size bytes)")
+
+	flags.Int("context-lines", 2, "amount of lines before and after a secret in a file shown as context (-1 to disable)")
+
+	flags.String("gitleaks-config", "", "path to a gitleaks TOML configuration file to import rules from")
 
-	Command.AddCommand(static, dynamic)
+	addCacheDirFlag(flags)
+	flags.Bool("no-cache", false, "search every layer instead of reusing the findings of layers searched before")
 }
 
-// parseContext will parse the context and return the parsed [image.Image] and [secrets.Detector]
-// set by the [Command] PersistentPreRun hook. If the context is not set for both, the program will exit.
-func parseContext(ctx context.Context) (image.Image, secrets.Detector) {
-	img, ok := ctx.Value(imageContextKey).(image.Image)
-	if !ok {
-		logging.Fatal(errorMsgFmt, "error parsing image context")
+// addCacheDirFlag will add the flag configuring the directory of the layer cache
+func addCacheDirFlag(flags *pflag.FlagSet) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		logrus.Debugf("unable to determine user cache directory: %s", err)
 	}
+	flags.String("cache-dir", dir, "directory the findings of each layer are cached in")
+}
+
+// layerCache will open the cache of the findings of each layer searched by the detector,
+// returning nil if caching is disabled or the cache cannot be opened
+func layerCache(cmd *cobra.Command, detector secrets.Detector) *analysis.LayerCache {
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		return nil
+	}
+	if secrets.Unmasked() {
+		logrus.Infof("secrets are unmasked, layers will not be cached")
+		return nil
+	}
+	dir, _ := cmd.Flags().GetString("cache-dir")
+	if dir == "" {
+		logrus.Warnf("no cache directory set, layers will not be cached")
+		return nil
+	}
+	store, err := cache.New(dir)
+	if err != nil {
+		logrus.Warnf("layers will not be cached: %s", err)
+		return nil
+	}
+	return analysis.NewLayerCache(store, detector, config.ToolVersion())
+}
+
+// loadRules will parse the configuration file and the rules it configures,
+// exiting if the configuration or any rule is invalid
+func loadRules(cmd *cobra.Command) (cfg config.File, staticRules []secrets.StaticRule, dynamicRules []secrets.DynamicRule) {
+	// the flag is bound when the command runs, since multiple commands define it
+	if err := viper.BindPFlag(config.ViperGitleaksKey, cmd.Flags().Lookup("gitleaks-config")); err != nil {
+		logging.Fatal(err.Error())
+	}
+
+	spnr := logging.StartSpinner("parsing configuration...")
+	err := viper.Unmarshal(&cfg)
 
-	detector, ok := ctx.Value(detectorContextKey).(secrets.Detector)
-	if !ok {
-		logging.Fatal(errorMsgFmt, "error parsing detector context")
+	logrus.Infof("parsing regular expression detection configuration")
+	staticRules, invalidStaticRules := secrets.ParseStaticRules(cfg.StaticRules)
+	dynamicRules, invalidDynamicRules := secrets.ParseDynamicRules(cfg.DynamicRules)
+	var invalidGitleaksRules []config.GitleaksRule
+	if err == nil && cfg.GitleaksConfig != "" {
+		logrus.Infof("parsing gitleaks configuration %s", cfg.GitleaksConfig)
+		var gitleaksCfg config.GitleaksConfig
+		if gitleaksCfg, err = config.LoadGitleaksConfig(cfg.GitleaksConfig); err == nil {
+			var gitleaksStatic []secrets.StaticRule
+			var gitleaksDynamic []secrets.DynamicRule
+			gitleaksStatic, gitleaksDynamic, invalidGitleaksRules = secrets.ParseGitleaksRules(gitleaksCfg)
+			staticRules = append(staticRules, gitleaksStatic...)
+			dynamicRules = append(dynamicRules, gitleaksDynamic...)
+		}
 	}
 
-	return img, detector
+	logging.FinishSpinnerWithError(spnr, err)
+	for _, iR := range invalidStaticRules {
+		logrus.Errorf("invalid static rule 'pattern: %s'", iR.Pattern)
+	}
+	for _, iR := range invalidDynamicRules {
+		logrus.Errorf("invalid dynamic rule 'pattern: %s, file: %s'", iR.Pattern, iR.FilePattern)
+	}
+	for _, iR := range invalidGitleaksRules {
+		logrus.Errorf("invalid gitleaks rule 'id: %s, regex: %s, path: %s'", iR.ID, iR.Regex, iR.Path)
+	}
+	if len(invalidStaticRules) > 0 || len(invalidDynamicRules) > 0 || len(invalidGitleaksRules) > 0 {
+		if !cfg.IgnoreInvalidRules {
+			logging.Fatal("invalid rules found, exiting due to flag `ignore-invalid` not set")
+		}
+	}
+	return cfg, staticRules, dynamicRules
+}
+
+// ruleset is the configuration and rules of a scan, parsed
+// by the [Command] PersistentPreRun hook
+type ruleset struct {
+	cfg     config.File
+	static  []secrets.StaticRule
+	dynamic []secrets.DynamicRule
+}
+
+// newDetector will create the detector of a scan of the image with the given name,
+// using the allowlists of the configuration that apply to the image
+func newDetector(cmd *cobra.Command, cfg config.File, staticRules []secrets.StaticRule, dynamicRules []secrets.DynamicRule, imageName string) (secrets.Detector, error) {
+	allowlists, err := secrets.ParseAllowlists(cfg.Allowlists)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowlists: %s", err)
+	}
+	imageAllowlists, err := secrets.ParseImageAllowlists(cfg.ImageAllowlists, imageName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image allowlists: %s", err)
+	}
+
+	contextLines, _ := cmd.Flags().GetInt("context-lines")
+	return secrets.NewDetector(
+		secrets.Opts{
+			UseDefaultStaticRules:  !cfg.ExcludeDefaultStaticRules,
+			UseDefaultDynamicRules: !cfg.ExcludeDefaultDynamicRules,
+			MaxFileSize:            maxFileSize(cmd),
+			OversizedFiles:         oversizedPolicy(cmd),
+			ContextLines:           contextLines,
+			Allowlists:             append(allowlists, imageAllowlists...),
+		},
+		staticRules,
+		dynamicRules,
+	), nil
+}
+
+// openImage will open the image read from the OCI layout ociDir, the archive at archivePath,
+// or the registry or docker daemon, in that order of precedence. The program exits if the image cannot be opened
+func openImage(cmd *cobra.Command, imageName, archivePath, ociDir string) image.Image {
+	var msg string
+	switch {
+	case ociDir != "":
+		msg = "reading OCI image layout..."
+	case archivePath != "":
+		msg = "reading image archive..."
+	case useRegistry(cmd):
+		msg = "fetching image from registry..."
+	default:
+		msg = "connecting to docker daemon..."
+	}
+	spnr := logging.StartSpinner(msg)
+	i, err := newImageOpener(cmd, archivePath, ociDir).open(imageName)
+	logging.FinishSpinnerWithError(spnr, err)
+	return i
+}
+
+// scanInfo will describe the scan of the image with the detector and layer cache,
+// and the sources searched by the analysis set in the context
+func scanInfo(ctx context.Context, img image.Image, detector secrets.Detector, layerCache *analysis.LayerCache) analysis.ScanInfo {
+	sources, _ := ctx.Value(sourcesContextKey).([]analysis.Source)
+	start, _ := ctx.Value(startTimeContextKey).(time.Time)
+	digest, err := img.Digest()
+	if err != nil {
+		logrus.Warnf("unable to determine image digest: %s", err)
+	}
+	return analysis.ScanInfo{
+		Image:      img.Name(),
+		Digest:     digest,
+		Rules:      detector.Rules(),
+		Sources:    sources,
+		Time:       start,
+		Suppressed: detector.Suppressed() + layerCache.Suppressed(),
+	}
+}
+
+// useRegistry will return true if the image should be read directly from its registry
+func useRegistry(cmd *cobra.Command) bool {
+	remote, _ := cmd.Flags().GetBool("registry")
+	insecure, _ := cmd.Flags().GetBool("insecure-registry")
+	return remote || insecure
+}
+
+// registryPasswordEnv is the environment variable the registry password is read from,
+// if it is not given by a flag, so it is not exposed in the process list or shell history
+const registryPasswordEnv = "DOCKERLEAKS_REGISTRY_PASSWORD"
+
+// stdinPassword is the registry password read from stdin, which is only read once
+var stdinPassword struct {
+	once     sync.Once
+	password string
+	err      error
+}
+
+// registryOpts will parse the registry client options from the command flags, reading the
+// password from `registry-password`, stdin if `registry-password-stdin` is set, or registryPasswordEnv
+func registryOpts(cmd *cobra.Command) registry.Opts {
+	insecure, _ := cmd.Flags().GetBool("insecure-registry")
+	username, _ := cmd.Flags().GetString("registry-username")
+	password, _ := cmd.Flags().GetString("registry-password")
+	if fromStdin, _ := cmd.Flags().GetBool("registry-password-stdin"); password == "" && fromStdin {
+		stdinPassword.once.Do(func() {
+			var raw []byte
+			raw, stdinPassword.err = io.ReadAll(os.Stdin)
+			stdinPassword.password = strings.TrimRight(string(raw), "\r\n")
+		})
+		if stdinPassword.err != nil {
+			logging.Fatal("unable to read registry password from stdin: %s\n", stdinPassword.err)
+		}
+		password = stdinPassword.password
+	}
+	if password == "" {
+		password = os.Getenv(registryPasswordEnv)
+	}
+	return registry.Opts{
+		Insecure: insecure,
+		Username: username,
+		Password: password,
+	}
+}
+
+// addContainerFlags will add the flags configuring a started container to the command
+func addContainerFlags(cmd *cobra.Command) {
+	memory := units.BytesSize(float64(image.DefaultContainerOpts.Memory))
+	cmd.Flags().String("memory", memory, "memory limit of the started container")
+	cmd.Flags().Float64("cpus", image.DefaultContainerOpts.CPUs, "amount of CPUs the started container may use")
+}
+
+// containerOpts will parse the container options from the command flags
+func containerOpts(cmd *cobra.Command) image.ContainerOpts {
+	opts := image.DefaultContainerOpts
+	opts.CPUs, _ = cmd.Flags().GetFloat64("cpus")
+	memory, _ := cmd.Flags().GetString("memory")
+	limit, err := units.RAMInBytes(memory)
+	if err != nil {
+		logging.Fatal("invalid memory limit '%s': %s\n", memory, err)
+	}
+	opts.Memory = limit
+	return opts
+}
+
+// maxFileSize will parse the maximum size of a searched file from the command flags
+func maxFileSize(cmd *cobra.Command) int64 {
+	value, _ := cmd.Flags().GetString("max-file-size")
+	size, err := units.RAMInBytes(value)
+	if err != nil || size < 0 {
+		logging.Fatal("invalid maximum file size '%s'\n", value)
+	}
+	return size
+}
+
+// oversizedPolicy will parse the policy for files larger than the maximum file size from the command flags
+func oversizedPolicy(cmd *cobra.Command) secrets.OversizedPolicy {
+	value, _ := cmd.Flags().GetString("oversized-files")
+	switch policy := secrets.OversizedPolicy(value); policy {
+	case secrets.SkipOversized, secrets.ScanOversized:
+		return policy
+	default:
+		logging.Fatal("invalid oversized file policy '%s', must be one of: skip, scan\n", value)
+		return ""
+	}
 }
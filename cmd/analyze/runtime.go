@@ -0,0 +1,57 @@
+package analyze
+
+import (
+	"context"
+	"github.com/bthuilot/dockerleaks/pkg/analysis"
+	"github.com/bthuilot/dockerleaks/pkg/image/container"
+	"github.com/bthuilot/dockerleaks/pkg/logging"
+	"github.com/spf13/cobra"
+	"regexp"
+	"time"
+)
+
+var runtimeCmd = &cobra.Command{
+	Use:   "runtime",
+	Short: "Analyze a running container for secrets",
+	Long: `Analyze a running container by starting the image in a sandbox and, once ready,
+inspecting the environment and command line of each process and any files written at runtime.
+This runs the entrypoint of the image, so should only be used with trusted images`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := analysis.RuntimeOpts{
+			Container: containerOpts(cmd),
+			Ready:     readyCondition(cmd),
+		}
+		opts.Container.AllowNetwork, _ = cmd.Flags().GetBool("allow-network")
+		// files written at runtime are only visible if the root filesystem is writable
+		opts.Container.WritableRootfs = true
+
+		scanImages(cmd, analysis.RuntimeSources, func(ctx context.Context, s imageScan) ([]analysis.Finding, error) {
+			return analysis.Runtime(ctx, s.img, s.detector, opts)
+		})
+	},
+}
+
+func init() {
+	addContainerFlags(runtimeCmd)
+	runtimeCmd.Flags().Bool("allow-network", false, "allow the container to access the network")
+	runtimeCmd.Flags().Duration("ready-delay", 5*time.Second, "time to wait after the container starts before inspecting it")
+	runtimeCmd.Flags().String("ready-log", "", "wait until a line of the container logs matches this regular expression")
+	runtimeCmd.Flags().Bool("ready-healthy", false, "wait until the health check of the image reports healthy")
+	runtimeCmd.Flags().Duration("ready-timeout", time.Minute, "maximum time to wait for the container to be ready")
+}
+
+// readyCondition will parse the ready condition from the command flags
+func readyCondition(cmd *cobra.Command) container.ReadyCondition {
+	var cond container.ReadyCondition
+	cond.Delay, _ = cmd.Flags().GetDuration("ready-delay")
+	cond.Healthy, _ = cmd.Flags().GetBool("ready-healthy")
+	cond.Timeout, _ = cmd.Flags().GetDuration("ready-timeout")
+	if pattern, _ := cmd.Flags().GetString("ready-log"); pattern != "" {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			logging.Fatal("invalid ready log pattern '%s': %s\n", pattern, err)
+		}
+		cond.LogPattern = regex
+	}
+	return cond
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"github.com/bthuilot/dockerleaks/pkg/cache"
+	"github.com/bthuilot/dockerleaks/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of layer findings",
+	Long: `Manage the cache of the findings of each layer searched, which is
+reused by later scans of any image sharing the layer`,
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old entries from the cache",
+	Long: `Remove the entries of the cache not used within --older-than,
+or every entry if --all is given`,
+	Example: `  dockerleaks cache prune --older-than 168h
+  dockerleaks cache prune --all`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("cache-dir")
+		if dir == "" {
+			logging.Fatal("flag `cache-dir` must be set\n")
+		}
+		maxAge, _ := cmd.Flags().GetDuration("older-than")
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			maxAge = 0
+		} else if maxAge <= 0 {
+			logging.Fatal("flag `older-than` must be positive, use `all` to remove every entry\n")
+		}
+
+		c, err := cache.New(dir)
+		if err != nil {
+			logging.Fatal("%s\n", err)
+		}
+		spnr := logging.StartSpinner("pruning cache...")
+		removed, err := c.Prune(maxAge)
+		logging.FinishSpinnerWithError(spnr, err) // Exit if error
+		logging.Msg("removed %d entries from %s\n", removed, c.Dir())
+	},
+}
+
+func init() {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		logrus.Debugf("unable to determine user cache directory: %s", err)
+	}
+	pruneCmd.Flags().String("cache-dir", dir, "directory the findings of each layer are cached in")
+	pruneCmd.Flags().Duration("older-than", 30*24*time.Hour, "remove entries not used within this duration")
+	pruneCmd.Flags().Bool("all", false, "remove every entry")
+	cacheCmd.AddCommand(pruneCmd)
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/bthuilot/dockerleaks/pkg/logging"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"log"
 )
 
 var rootCmd = &cobra.Command{
@@ -21,7 +20,7 @@ and you are welcome to redistribute it under certain conditions.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := cmd.Help(); err != nil {
-			log.Fatal(err)
+			logging.Fatal(err.Error())
 		}
 	},
 }
@@ -39,13 +38,14 @@ func init() {
 	rootCmd.PersistentFlags().StringP("config", "c", "./", "path to config file")
 	rootCmd.PersistentFlags().BoolP("unmask", "u", false, "secret values should be unmasked")
 
-	rootCmd.AddCommand(analyze.Command)
+	rootCmd.Version = config.ToolVersion()
+	rootCmd.AddCommand(analyze.Command, analyze.DiffCommand, cacheCmd)
 	if err := rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml"); err != nil {
-		log.Fatalf("err marking config as filename %s", err)
+		logging.Fatal("error marking config as filename: %s", err)
 	}
 
 	if err := viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config")); err != nil {
-		log.Fatalf("err binding config %s", err)
+		logging.Fatal("error binding config: %s", err)
 	}
 
 }
@@ -54,7 +54,7 @@ func bindFlags() {
 	_ = viper.BindPFlag(config.ViperLogLevelKey, rootCmd.PersistentFlags().Lookup("log-level"))
 	_ = viper.BindPFlag(config.ViperDisableColorKey, rootCmd.PersistentFlags().Lookup("disable-color"))
 	if err := viper.BindPFlag(config.ViperUnmaskKey, rootCmd.PersistentFlags().Lookup("unmask")); err != nil {
-		log.Fatalf("err binding reveal %s", err)
+		logging.Fatal("error binding unmask: %s", err)
 	}
 }
 
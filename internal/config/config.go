@@ -9,6 +9,7 @@ const (
 	ViperUnmaskKey       = "unmaskValues"
 	ViperExcludeKey      = "excludeDefaultRules"
 	ViperDisableColorKey = "disableColor"
+	ViperGitleaksKey     = "gitleaksConfig"
 )
 
 // File is the user configuration file for the application
@@ -19,6 +20,9 @@ type File struct {
 	// DynamicRules is the list of user defined rules for matching secret strings
 	// during a dynamic container analysis
 	DynamicRules []UserDynamicRule
+	// GitleaksConfig is the path to a gitleaks TOML configuration file,
+	// whose rules are used alongside StaticRules and DynamicRules
+	GitleaksConfig string
 	// IgnoreInvalidRules will ignore any invalid rules in the configuration
 	// file if set to true
 	IgnoreInvalidRules bool
@@ -30,6 +34,10 @@ type File struct {
 	// secret strings or files during a dynamic scan. See the variable [secrets.DefaultDynamicRules] for the full
 	// list of defaults
 	ExcludeDefaultDynamicRules bool
+	// Allowlists are conditions under which a match of any rule is not a secret
+	Allowlists []UserAllowlist
+	// ImageAllowlists are allowlists that only apply to the images they match
+	ImageAllowlists []UserImageAllowlist
 }
 
 // UserStaticRule represents a user defined string pattern/entropy
@@ -45,6 +53,17 @@ type UserStaticRule struct {
 	Name string
 	// MinEntropy is the minimum entropy the string should have
 	MinEntropy float64
+	// SecretGroup is the capture group of Pattern containing the secret,
+	// a value of 0 means that the full match is the secret
+	SecretGroup int
+	// Keywords are strings, one of which must be present (ignoring case)
+	// in the text for the rule to be checked. Rules without keywords are always checked
+	Keywords []string
+	// Severity is how severe the leak of a secret matched by the rule is,
+	// one of low, medium (default), high or critical
+	Severity string
+	// Allowlists are conditions under which a match of the rule is not a secret
+	Allowlists []UserAllowlist
 }
 
 type UserDynamicRule struct {
@@ -60,6 +79,44 @@ type UserDynamicRule struct {
 	Pattern string
 	// MinEntropy is the minimum entropy the string should have
 	MinEntropy float64
+	// SecretGroup is the capture group of Pattern containing the secret,
+	// a value of 0 means that the full match is the secret
+	SecretGroup int
+	// Keywords are strings, one of which must be present (ignoring case)
+	// in the text for the rule to be checked. Rules without keywords are always checked
+	Keywords []string
+	// Severity is how severe the leak of a secret matched by the rule is,
+	// one of low, medium (default), high or critical
+	Severity string
+	// Allowlists are conditions under which a match of the rule is not a secret
+	Allowlists []UserAllowlist
+}
+
+// UserAllowlist represents a user defined set of conditions under
+// which a match is not a secret. A match is allowed if it meets any condition
+type UserAllowlist struct {
+	// Description is a human-readable description of the allowlist
+	Description string
+	// Paths are regular expressions matching the paths of files that are allowed
+	Paths []string
+	// Variables are regular expressions matching the names of
+	// environment variables and build arguments that are allowed
+	Variables []string
+	// Regexes are regular expressions matching secrets that are allowed
+	Regexes []string
+	// Hashes are the hex encoded SHA-256 hashes of secrets that are allowed
+	Hashes []string
+	// StopWords are strings that, if contained in the secret (ignoring case), allow it
+	StopWords []string
+}
+
+// UserImageAllowlist represents a user defined allowlist
+// that only applies to the images matching Image
+type UserImageAllowlist struct {
+	// Image is a regular expression matching the names of the images the allowlist applies to
+	Image string
+	// UserAllowlist is the allowlist applied to the images
+	UserAllowlist `mapstructure:",squash"`
 }
 
 // Init will initialize the configuration of the application
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"github.com/pelletier/go-toml/v2"
+	"os"
+)
+
+// GitleaksConfig is a [gitleaks configuration file], of which
+// only the rules are used
+//
+// [gitleaks configuration file]: https://github.com/gitleaks/gitleaks#configuration
+type GitleaksConfig struct {
+	// Title is the title of the configuration
+	Title string `toml:"title"`
+	// Rules are the rules for detecting secrets
+	Rules []GitleaksRule `toml:"rules"`
+}
+
+// GitleaksRule is a single rule of a gitleaks configuration file
+type GitleaksRule struct {
+	// ID is the unique identifier of the rule
+	ID string `toml:"id"`
+	// Description is a human-readable description of the rule
+	Description string `toml:"description"`
+	// Regex is a regular expression for matching a secret
+	Regex string `toml:"regex"`
+	// SecretGroup is the capture group of Regex containing the secret
+	SecretGroup int `toml:"secretGroup"`
+	// Entropy is the minimum entropy the secret should have
+	Entropy float64 `toml:"entropy"`
+	// Keywords are strings, one of which must be present for the rule to match
+	Keywords []string `toml:"keywords"`
+	// Path is a regular expression for matching file paths
+	Path string `toml:"path"`
+	// Tags are arbitrary labels for the rule
+	Tags []string `toml:"tags"`
+	// Allowlist is the allowlist of the rule
+	Allowlist GitleaksAllowlist `toml:"allowlist"`
+	// Allowlists are the allowlists of the rule, used by newer versions of gitleaks
+	Allowlists []GitleaksAllowlist `toml:"allowlists"`
+}
+
+// GitleaksAllowlist is the allowlist of a gitleaks rule
+type GitleaksAllowlist struct {
+	// Description is a human-readable description of the allowlist
+	Description string `toml:"description"`
+	// Regexes are regular expressions for matching secrets that are allowed
+	Regexes []string `toml:"regexes"`
+	// RegexTarget is what Regexes are matched against, either
+	// the "secret" (default) or the full "match" of the rule
+	RegexTarget string `toml:"regexTarget"`
+	// Paths are regular expressions for matching file paths that are allowed
+	Paths []string `toml:"paths"`
+	// StopWords are strings that, if contained in the secret, allow it
+	StopWords []string `toml:"stopwords"`
+}
+
+// LoadGitleaksConfig will read the gitleaks configuration file located at path
+func LoadGitleaksConfig(path string) (GitleaksConfig, error) {
+	var cfg GitleaksConfig
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = toml.Unmarshal(raw, &cfg)
+	return cfg, err
+}
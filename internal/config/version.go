@@ -0,0 +1,34 @@
+package config
+
+import (
+	"runtime/debug"
+)
+
+// Version is the version of the application, set when building a release with
+// `-ldflags "-X github.com/bthuilot/dockerleaks/internal/config.Version=<version>"`
+var Version = ""
+
+// ToolVersion will return the version of the application, falling back to the
+// module version or the VCS revision the binary was built from if Version is not set
+func ToolVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	version := "devel"
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision":
+			version += "-" + s.Value
+		case s.Key == "vcs.modified" && s.Value == "true":
+			version += "-dirty"
+		}
+	}
+	return version
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"os"
+)
+
+// Baseline is the set of fingerprints of previously accepted findings,
+// which are suppressed from the findings of later scans
+type Baseline map[string]struct{}
+
+// baselineFinding is the part of a finding in a JSON report read by a baseline
+type baselineFinding struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
+// LoadBaseline will read the fingerprints of the findings in the JSON report located at path
+func LoadBaseline(path string) (Baseline, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		logrus.Errorf("error reading baseline %s: %s", path, err)
+		return nil, errors.New("unable to read baseline")
+	}
+	var findings []baselineFinding
+	if err = json.Unmarshal(raw, &findings); err != nil {
+		logrus.Errorf("error parsing baseline %s: %s", path, err)
+		return nil, errors.New("unable to parse baseline, expected a JSON report")
+	}
+	baseline := make(Baseline, len(findings))
+	for _, f := range findings {
+		if f.Fingerprint != "" {
+			baseline[f.Fingerprint] = struct{}{}
+		}
+	}
+	return baseline, nil
+}
+
+// Filter will return the findings not in the baseline, and the amount of findings suppressed
+func (b Baseline) Filter(findings []Finding) ([]Finding, int) {
+	kept := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		if _, ok := b[f.Fingerprint]; !ok {
+			kept = append(kept, f)
+		}
+	}
+	return kept, len(findings) - len(kept)
+}
+
+// WriteBaseline will write the findings to path as a JSON report usable as a baseline.
+// The secrets and context of the findings are omitted, so the baseline never contains a secret
+func WriteBaseline(path string, findings []Finding) error {
+	stripped := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		f.Secret, f.Context = "", nil
+		stripped = append(stripped, f)
+	}
+	raw, err := JSONFormatter(stripped)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, []byte(raw+"\n"), 0o600); err != nil {
+		logrus.Errorf("error writing baseline %s: %s", path, err)
+		return errors.New("unable to write baseline")
+	}
+	return nil
+}
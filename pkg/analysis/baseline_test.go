@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	rule := secrets.StaticRule{Name: "Generic"}
+	accepted := Finding{Rule: rule, Source: EnvVar, Variable: "TOKEN", Secret: "abcdefgh", Fingerprint: "accepted"}
+	added := Finding{Rule: rule, Source: File, Path: "app/.env", Secret: "ijklmnop", Fingerprint: "added"}
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := WriteBaseline(path, []Finding{accepted}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), accepted.Secret) {
+		t.Errorf("Expected baseline to not contain the secret, got %s", raw)
+	}
+
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	kept, suppressed := baseline.Filter([]Finding{accepted, added})
+	if suppressed != 1 || len(kept) != 1 || kept[0].Fingerprint != added.Fingerprint {
+		t.Errorf("Expected only the added finding to be kept, got %v (%d suppressed)", kept, suppressed)
+	}
+}
@@ -0,0 +1,190 @@
+package analysis
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/cache"
+	"github.com/bthuilot/dockerleaks/pkg/image"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"github.com/sirupsen/logrus"
+	"hash"
+	"io"
+	"sync"
+)
+
+// LayerCache stores the findings of every file of each layer searched, so that layers
+// shared between images or scans are only searched once. Entries are keyed by the digest
+// of the layer, the hash of the detector, the version of the application and whether
+// secrets are unmasked, since the findings of a layer only depend on those.
+// A nil LayerCache does not cache any layer.
+type LayerCache struct {
+	store cache.Cache
+	// prefix is the part of the key of each entry that is not the layer digest
+	prefix string
+	// rules are the rules of the detector by their ID, to restore the rule of a cached finding
+	rules map[string]secrets.Rule
+
+	// mu guards hits and suppressed
+	mu sync.Mutex
+	// hits is the amount of layers read from the cache
+	hits int
+	// suppressed is the amount of matches allowed by an allowlist in the layers read from the cache
+	suppressed int
+}
+
+// layerResult is the cached result of searching a layer
+type layerResult struct {
+	// Entries are the entries of the layer, to reconstruct the image filesystem
+	Entries []layerEntry `json:"entries"`
+	// Findings are the findings of every file of the layer
+	Findings []cachedFinding `json:"findings"`
+	// Suppressed is the amount of matches in the layer allowed by an allowlist
+	Suppressed int `json:"suppressed"`
+}
+
+// layerEntry is the part of an entry of a layer needed to reconstruct the image filesystem
+type layerEntry struct {
+	Name string `json:"name"`
+	Type byte   `json:"type"`
+}
+
+// cachedFinding is a finding with its rule replaced by the ID of the rule
+type cachedFinding struct {
+	Finding
+	// Rule is the ID of the rule of the finding, shadowing the rule of Finding
+	Rule string `json:"rule"`
+}
+
+// NewLayerCache will create a LayerCache stored in store, for the
+// findings of the detector in the given version of the application
+func NewLayerCache(store cache.Cache, detector secrets.Detector, version string) *LayerCache {
+	c := &LayerCache{store: store, rules: make(map[string]secrets.Rule)}
+	for _, r := range detector.Rules() {
+		c.rules[r.RuleID()] = r
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s", version, detector.Hash())))
+	c.prefix = "layer:" + hex.EncodeToString(sum[:])
+	return c
+}
+
+// Hits will return the amount of layers read from the cache
+func (c *LayerCache) Hits() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits
+}
+
+// Suppressed will return the amount of matches allowed by an allowlist in the layers read from the
+// cache, which are not counted by the detector since the layers are not searched again
+func (c *LayerCache) Suppressed() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.suppressed
+}
+
+// disabled will return true if no layer is cached, since the cache is nil or secrets
+// are unmasked. Findings only hold masked secrets otherwise, which are safe to store
+func (c *LayerCache) disabled() bool {
+	return c == nil || secrets.Unmasked()
+}
+
+// load will return the findings of the layer at index idx if it is cached,
+// applying each entry of the layer to the merger
+func (c *LayerCache) load(idx int, l image.Layer, merger *image.Merger) ([]Finding, bool) {
+	if c.disabled() || l.Digest() == "" {
+		return nil, false
+	}
+	raw, ok := c.store.Get(c.prefix + ":" + l.Digest())
+	if !ok {
+		return nil, false
+	}
+	var result layerResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		logrus.Warnf("ignoring invalid cache entry of layer %s: %s", l.Digest(), err)
+		return nil, false
+	}
+	findings := make([]Finding, 0, len(result.Findings))
+	for _, cf := range result.Findings {
+		f := cf.Finding
+		if f.Rule, ok = c.rules[cf.Rule]; !ok {
+			logrus.Warnf("ignoring cache entry of layer %s with unknown rule %s", l.Digest(), cf.Rule)
+			return nil, false
+		}
+		findings = append(findings, f)
+	}
+
+	for _, e := range result.Entries {
+		merger.Apply(idx, &tar.Header{Name: e.Name, Typeflag: e.Type})
+	}
+	logrus.Infof("using cached findings of layer %s", l.Digest())
+	c.mu.Lock()
+	c.hits++
+	c.suppressed += result.Suppressed
+	c.mu.Unlock()
+	return findings, true
+}
+
+// save will cache the findings of every file of the layer, failing silently
+// since the layer is searched again if it is not cached. The layer is only cached if
+// digest, the digest of the content searched, matches the digest the image declares
+// for the layer, so an image cannot replace the cached findings of another image's layer
+func (c *LayerCache) save(l image.Layer, digest string, entries []layerEntry, findings []Finding, suppressed int) {
+	if c.disabled() || l.Digest() == "" {
+		return
+	}
+	if digest != l.Digest() {
+		logrus.Warnf("not caching layer %s, its content has digest %s", l.Digest(), digest)
+		return
+	}
+	result := layerResult{
+		Entries:    entries,
+		Findings:   make([]cachedFinding, 0, len(findings)),
+		Suppressed: suppressed,
+	}
+	for _, f := range findings {
+		result.Findings = append(result.Findings, cachedFinding{Finding: f, Rule: f.Rule.RuleID()})
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		logrus.Warnf("unable to encode findings of layer %s: %s", l.Digest(), err)
+		return
+	}
+	if err = c.store.Put(c.prefix+":"+l.Digest(), raw); err != nil {
+		logrus.Warnf("unable to cache findings of layer %s: %s", l.Digest(), err)
+	}
+}
+
+// digestReader computes the digest of the uncompressed content of a layer as it is read
+type digestReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+// newDigestReader will wrap the uncompressed content of a layer to compute its digest
+func newDigestReader(r io.Reader) *digestReader {
+	return &digestReader{r: r, h: sha256.New()}
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.h.Write(p[:n])
+	return n, err
+}
+
+// digest will read the rest of the content, such as the padding after the end
+// of a tar archive, and return the digest of the content in the form 'sha256:<hex>'
+func (d *digestReader) digest() (string, error) {
+	if _, err := io.Copy(io.Discard, d); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(d.h.Sum(nil)), nil
+}
@@ -0,0 +1,47 @@
+package analysis
+
+// Change is how a finding changed between two images
+type Change string
+
+const (
+	// Added findings are only found in the new image
+	Added Change = "added"
+	// Removed findings are only found in the old image
+	Removed Change = "removed"
+	// Unchanged findings are found in both images
+	Unchanged Change = "unchanged"
+)
+
+// Diff will compare the findings of an old and a new image by their fingerprint, returning
+// the added, removed and unchanged findings in that order with their Change set.
+// A fingerprint found more times in the new image than the old is added for each extra time
+func Diff(oldFindings, newFindings []Finding) []Finding {
+	remaining := make(map[string]int, len(oldFindings))
+	for _, f := range oldFindings {
+		remaining[f.Fingerprint]++
+	}
+
+	var added, removed, unchanged []Finding
+	for _, f := range newFindings {
+		if remaining[f.Fingerprint] > 0 {
+			remaining[f.Fingerprint]--
+			f.Change = Unchanged
+			unchanged = append(unchanged, f)
+			continue
+		}
+		f.Change = Added
+		added = append(added, f)
+	}
+	for _, f := range oldFindings {
+		if remaining[f.Fingerprint] > 0 {
+			remaining[f.Fingerprint]--
+			f.Change = Removed
+			removed = append(removed, f)
+		}
+	}
+
+	diff := make([]Finding, 0, len(added)+len(removed)+len(unchanged))
+	diff = append(diff, added...)
+	diff = append(diff, removed...)
+	return append(diff, unchanged...)
+}
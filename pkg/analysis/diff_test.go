@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	rule := secrets.StaticRule{Name: "Generic"}
+	finding := func(fingerprint string) Finding {
+		return Finding{Rule: rule, Source: File, Path: fingerprint, Fingerprint: fingerprint}
+	}
+
+	diff := Diff(
+		[]Finding{finding("kept"), finding("removed"), finding("twice")},
+		[]Finding{finding("twice"), finding("added"), finding("kept"), finding("twice")},
+	)
+	expected := []struct {
+		fingerprint string
+		change      Change
+	}{
+		{"added", Added},
+		{"twice", Added},
+		{"removed", Removed},
+		{"twice", Unchanged},
+		{"kept", Unchanged},
+	}
+	if len(diff) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d", len(expected), len(diff))
+	}
+	for i, e := range expected {
+		if diff[i].Fingerprint != e.fingerprint || diff[i].Change != e.change {
+			t.Errorf("Expected %s %s at %d, got %s %s", e.fingerprint, e.change, i, diff[i].Fingerprint, diff[i].Change)
+		}
+	}
+}
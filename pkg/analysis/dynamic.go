@@ -1,16 +1,44 @@
 package analysis
 
 import (
+	"archive/tar"
+	"context"
+	"errors"
 	"github.com/bthuilot/dockerleaks/pkg/image"
 	"github.com/bthuilot/dockerleaks/pkg/secrets"
 	"github.com/sirupsen/logrus"
-	"io"
 )
 
-func Dynamic(img image.Image, detector secrets.Detector) ([]Finding, error) {
+// DynamicOpts is used to configure a dynamic analysis
+type DynamicOpts struct {
+	// Container are the options for the container created from the image
+	Container image.ContainerOpts
+	// Workers is the amount of files searched concurrently
+	Workers int
+	// Cache is the cache of the findings of each layer. If set, the layers of the image are searched
+	// even if it is backed by a docker daemon, unless the container is started. A nil value means no layer is cached
+	Cache *LayerCache
+}
+
+// Dynamic will search the final filesystem of the image for secrets. Images backed
+// by a docker daemon are searched by exporting a container created with the given options
+// if the container is started or layers are not cached, otherwise the filesystem is
+// reconstructed from the layers of the image.
+// The findings are sorted by the path of the file they were found in.
+func Dynamic(ctx context.Context, img image.Image, detector secrets.Detector, opts DynamicOpts) ([]Finding, error) {
+	// the filesystem of a started container may differ from the layers of the image
+	if !opts.Container.Start && !opts.Cache.disabled() {
+		logrus.Infof("layers are cached, scanning image layers")
+		return dynamicLayers(ctx, img, detector, opts)
+	}
+
 	// create a container from the image
 	logrus.Infof("creating container from image")
-	container, err := img.CreateContainer()
+	container, err := img.CreateContainer(opts.Container)
+	if errors.Is(err, image.ErrNoDaemon) {
+		logrus.Infof("image is not backed by a docker daemon, scanning image layers")
+		return dynamicLayers(ctx, img, detector, opts)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -27,30 +55,83 @@ func Dynamic(img image.Image, detector secrets.Detector) ([]Finding, error) {
 		return nil, err
 	}
 
-	var findings []Finding
-	for {
-		hdr, err := fs.Next()
-		if err == io.EOF {
-			logrus.Debugf("end of filesystem")
-			break
-		}
-		if err != nil {
-			logrus.Errorf("error reading filesystem: %s", err)
-			return nil, err
+	s := newScanner(ctx, detector, opts.Workers)
+	_ = s.searchTar(fs, func(*tar.Header) bool { return true })
+	return s.wait()
+}
+
+// dynamicLayers will search the files of the final image filesystem, by merging the layers
+// of the image, without creating a container. Every file of each layer is searched so that
+// the findings of a layer can be cached, keeping only those of files present in the final image
+func dynamicLayers(ctx context.Context, img image.Image, detector secrets.Detector, opts DynamicOpts) ([]Finding, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		findings []Finding
+		// findingLayers is the index of the layer each finding was found in
+		findingLayers []int
+		merger        = image.NewMerger(layers)
+	)
+	for idx, l := range layers {
+		layerFindings, ok := opts.Cache.load(idx, l, merger)
+		if !ok {
+			logrus.Infof("searching layer %s", l.Digest())
+			suppressed := detector.Suppressed()
+			var (
+				entries []layerEntry
+				digest  string
+			)
+			if layerFindings, entries, digest, err = searchLayerFiles(ctx, idx, l, detector, merger, opts.Workers); err != nil {
+				return nil, err
+			}
+			opts.Cache.save(l, digest, entries, layerFindings, detector.Suppressed()-suppressed)
 		}
-		logrus.Debugf("checking file %s", hdr.Name)
-		matches, err := detector.SearchFile(hdr.Name, io.LimitReader(fs, hdr.Size))
-		if err != nil {
-			return nil, err
+		for _, f := range layerFindings {
+			findings = append(findings, f)
+			findingLayers = append(findingLayers, idx)
 		}
-		for _, m := range matches {
-			findings = append(findings, Finding{
-				Secret: m.Secret.String(),
-				Rule:   m.Rule,
-				Source: File,
-				Path:   hdr.Name,
-			})
+	}
+
+	// only keep the findings of files present in the final image
+	visible := make([]Finding, 0, len(findings))
+	for i, f := range findings {
+		if p, ok := merger.Provenance(f.Path, findingLayers[i]); ok && p.Visible() {
+			visible = append(visible, f)
 		}
 	}
-	return findings, nil
+	sortFindings(visible)
+	return visible, nil
+}
+
+// searchLayerFiles will search each file of the layer concurrently, applying each entry of the
+// layer to the merger and returning the entries applied and the digest of the content of the layer
+func searchLayerFiles(ctx context.Context, idx int, l image.Layer, detector secrets.Detector, merger *image.Merger, workers int) ([]Finding, []layerEntry, string, error) {
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer rc.Close()
+
+	var (
+		entries []layerEntry
+		content = newDigestReader(rc)
+	)
+	s := newScanner(ctx, detector, workers)
+	_ = s.searchTar(tar.NewReader(content), func(hdr *tar.Header) bool {
+		entries = append(entries, layerEntry{Name: hdr.Name, Type: hdr.Typeflag})
+		_, whiteout := merger.Apply(idx, hdr)
+		return !whiteout
+	})
+	findings, err := s.wait()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	digest, err := content.digest()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return findings, entries, digest, nil
 }
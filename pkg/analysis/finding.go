@@ -1,10 +1,13 @@
 package analysis
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/bthuilot/dockerleaks/pkg/secrets"
 	"strings"
+	"time"
 )
 
 type Source string
@@ -13,6 +16,41 @@ const (
 	BuildArgument Source = "build-arg"
 	EnvVar        Source = "env-var"
 	File          Source = "file"
+	// RuntimeFile is a file written by a running container
+	RuntimeFile Source = "runtime-file"
+	// ProcessEnv is the environment of a process in a running container
+	ProcessEnv Source = "process-env"
+	// ProcessCmdline is the command line of a process in a running container
+	ProcessCmdline Source = "process-cmdline"
+)
+
+var (
+	// StaticSources are the sources searched by a static analysis
+	StaticSources = []Source{EnvVar, BuildArgument}
+	// FileSources are the sources searched by a dynamic or layers analysis
+	FileSources = []Source{File}
+	// RuntimeSources are the sources searched by a runtime analysis
+	RuntimeSources = []Source{ProcessEnv, ProcessCmdline, RuntimeFile}
+)
+
+// searchesFiles will return true if the source is searched with dynamic rules
+func (s Source) searchesFiles() bool {
+	return s == File || s == RuntimeFile
+}
+
+// FileStatus is the status of a file found in a layer
+// relative to the final filesystem of the image
+type FileStatus string
+
+const (
+	// Visible files are present in the final image
+	Visible FileStatus = "visible"
+	// Shadowed files are overwritten by a later layer,
+	// but can still be recovered from the layer they were found in
+	Shadowed FileStatus = "shadowed"
+	// Deleted files are removed by a later layer,
+	// but can still be recovered from the layer they were found in
+	Deleted FileStatus = "deleted"
 )
 
 type Finding struct {
@@ -20,6 +58,48 @@ type Finding struct {
 	Rule   secrets.Rule `json:"rule"`
 	Source Source       `json:"source"`
 	Path   string       `json:"path,omitempty"`
+	// Variable is the name of the environment variable or build argument
+	Variable string `json:"variable,omitempty"`
+	// Line is the line of the file the secret starts on, starting from 1
+	Line int `json:"line,omitempty"`
+	// Column is the column of Line the secret starts on, starting from 1
+	Column int `json:"column,omitempty"`
+	// Context is the lines of the file surrounding the secret, with secrets masked
+	Context *secrets.Snippet `json:"context,omitempty"`
+	// Layer is the digest of the layer the file was found in
+	Layer string `json:"layer,omitempty"`
+	// CreatedBy is the history entry that created Layer
+	CreatedBy string `json:"created_by,omitempty"`
+	// Status is the status of the file in the final image
+	Status FileStatus `json:"status,omitempty"`
+	// Fingerprint identifies the finding between scans of an image,
+	// derived from the rule, source, location and value of the secret
+	Fingerprint string `json:"fingerprint"`
+	// Change is how the finding changed from an older image, if the images were compared
+	Change Change `json:"change,omitempty"`
+	// Image is the name of the image the secret was found in
+	Image string `json:"image,omitempty"`
+	// Digest is the digest of Image
+	Digest string `json:"digest,omitempty"`
+}
+
+// Location will return where the secret was found, which is the path of
+// the file or process, or the name of the variable
+func (f Finding) Location() string {
+	if f.Variable != "" {
+		return f.Variable
+	}
+	return f.Path
+}
+
+// fingerprint will return the fingerprint of a finding, which does not depend
+// on the position of the secret so that unrelated changes to a file keep it stable
+func fingerprint(rule secrets.Rule, source Source, location string, secret secrets.Secret) string {
+	secretSum := sha256.Sum256([]byte(secret.Value))
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		rule.RuleID(), string(source), location, hex.EncodeToString(secretSum[:]),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
 }
 
 func (f Finding) String() string {
@@ -28,13 +108,119 @@ func (f Finding) String() string {
 		lines = append(lines, fmt.Sprintf("Secret: %s", f.Secret))
 	}
 	lines = append(lines, fmt.Sprintf("Rule: %s", f.Rule))
+	lines = append(lines, fmt.Sprintf("Severity: %s", f.Rule.RuleSeverity()))
 	lines = append(lines, fmt.Sprintf("Source: %s", f.Source))
 	if f.Path != "" {
 		lines = append(lines, fmt.Sprintf("Path: %s", f.Path))
 	}
+	if f.Variable != "" {
+		lines = append(lines, fmt.Sprintf("Variable: %s", f.Variable))
+	}
+	if f.Line > 0 {
+		lines = append(lines, fmt.Sprintf("Line: %d (column %d)", f.Line, f.Column))
+	}
+	if f.Context != nil {
+		lines = append(lines, "Context:")
+		for i, l := range f.Context.Lines {
+			marker := " "
+			if f.Context.StartLine+i == f.Line {
+				marker = ">"
+			}
+			lines = append(lines, fmt.Sprintf("%s %5d | %s", marker, f.Context.StartLine+i, l))
+		}
+	}
+	if f.Layer != "" {
+		lines = append(lines, fmt.Sprintf("Layer: %s", f.Layer))
+	}
+	if f.CreatedBy != "" {
+		lines = append(lines, fmt.Sprintf("Created By: %s", f.CreatedBy))
+	}
+	if f.Status != "" {
+		lines = append(lines, fmt.Sprintf("Status: %s", f.Status))
+	}
+	if f.Change != "" {
+		lines = append(lines, fmt.Sprintf("Change: %s", f.Change))
+	}
+	if f.Image != "" {
+		lines = append(lines, fmt.Sprintf("Image: %s", imageRef(f.Image, f.Digest)))
+	}
 	return strings.Join(lines, "\n")
 }
 
+// fileFindings will convert the matches in a file into findings, masking
+// every secret found in the file within the context of each finding
+func fileFindings(matches []secrets.FileMatch, source Source) []Finding {
+	found := make([]secrets.Secret, 0, len(matches))
+	for _, m := range matches {
+		found = append(found, m.Secret)
+	}
+
+	findings := make([]Finding, 0, len(matches))
+	for _, m := range matches {
+		f := Finding{
+			Secret: m.Secret.String(),
+			Rule:   m.Rule,
+			Source: source,
+			Path:   m.Path,
+			Line:   m.Line,
+			Column: m.Column,
+
+			Fingerprint: fingerprint(m.Rule, source, m.Path, m.Secret),
+		}
+		if m.Context != nil {
+			masked := m.Context.Mask(found...)
+			f.Context = &masked
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// ScanInfo describes the scan that produced a set of findings,
+// for output formats that include information beyond the findings
+type ScanInfo struct {
+	// Image is the name of the scanned image, or the names of each image if several were scanned
+	Image string
+	// Digest is the digest of the scanned image, empty if several were scanned
+	Digest string
+	// BaseImage is the name of the image the scanned image was compared against, if any
+	BaseImage string
+	// BaseDigest is the digest of BaseImage
+	BaseDigest string
+	// Rules are the rules the image was scanned with
+	Rules []secrets.Rule
+	// Sources are the sources searched by the scan
+	Sources []Source
+	// Time is when the scan started
+	Time time.Time
+	// Suppressed is the amount of secrets found that were allowed by an allowlist
+	Suppressed int
+}
+
+// imageRef will return the name of the image pinned to its digest, if known
+func imageRef(name, digest string) string {
+	if digest == "" {
+		return name
+	}
+	// a name containing a digest already identifies the image
+	return strings.SplitN(name, "@", 2)[0] + "@" + digest
+}
+
+// ruleName will return the human-readable name of the rule, or its ID if it has no name
+func ruleName(r secrets.Rule) string {
+	var name string
+	switch typed := r.(type) {
+	case secrets.StaticRule:
+		name = typed.Name
+	case secrets.DynamicRule:
+		name = typed.Name
+	}
+	if name == "" {
+		return r.RuleID()
+	}
+	return name
+}
+
 type Formatter func([]Finding) (string, error)
 
 func DefaultFormatter(findings []Finding) (string, error) {
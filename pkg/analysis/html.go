@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	_ "embed"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// htmlReport is the template of the HTML report
+//
+//go:embed report.html
+var htmlReport string
+
+// htmlTemplate is the parsed template of the HTML report
+var htmlTemplate = template.Must(template.New("report.html").Funcs(template.FuncMap{
+	"ruleName": ruleName,
+	"add": func(a, b int) int {
+		return a + b
+	},
+}).Parse(htmlReport))
+
+// htmlData is the data the HTML report is executed with
+type htmlData struct {
+	TemplateData
+	// BySource is the amount of findings of each source
+	BySource []htmlCount
+	// ByRule is the amount of findings of each rule
+	ByRule []htmlCount
+	// ByChange is the amount of findings of each change,
+	// only present if the findings are of a comparison between images
+	ByChange []htmlCount
+	// ByImage is the amount of findings of each image,
+	// only present if the findings are of more than one image
+	ByImage []htmlCount
+	// Layers are the findings grouped by the layer they were found in,
+	// only present if the findings have layer information
+	Layers []htmlLayer
+}
+
+type htmlCount struct {
+	Name  string
+	Count int
+}
+
+type htmlLayer struct {
+	Digest    string
+	CreatedBy string
+	Findings  []Finding
+}
+
+// HTMLFormatter will return a Formatter that outputs the findings as a self-contained
+// HTML report, with a summary of the findings, a sortable table of the findings and the
+// findings grouped by layer. The report does not load any external resources.
+func HTMLFormatter(info ScanInfo) Formatter {
+	return func(findings []Finding) (string, error) {
+		data := htmlData{TemplateData: newTemplateData(info, findings)}
+
+		var (
+			bySource = make(map[string]int)
+			byRule   = make(map[string]int)
+			byChange = make(map[string]int)
+			byImage  = make(map[string]int)
+			layers   = make(map[string]int)
+		)
+		for _, f := range findings {
+			bySource[string(f.Source)]++
+			byRule[ruleName(f.Rule)]++
+			if f.Change != "" {
+				byChange[string(f.Change)]++
+			}
+			if f.Image != "" {
+				byImage[f.Image]++
+			}
+			if f.Layer == "" {
+				continue
+			}
+			idx, ok := layers[f.Layer]
+			if !ok {
+				idx = len(data.Layers)
+				layers[f.Layer] = idx
+				data.Layers = append(data.Layers, htmlLayer{Digest: f.Layer, CreatedBy: f.CreatedBy})
+			}
+			data.Layers[idx].Findings = append(data.Layers[idx].Findings, f)
+		}
+		data.BySource, data.ByRule = sortedCounts(bySource), sortedCounts(byRule)
+		if len(byChange) > 0 {
+			data.ByChange = sortedCounts(byChange)
+		}
+		if len(byImage) > 1 {
+			data.ByImage = sortedCounts(byImage)
+		}
+
+		var b strings.Builder
+		err := htmlTemplate.Execute(&b, data)
+		return b.String(), err
+	}
+}
+
+// sortedCounts will return the counts sorted by descending count, then by name
+func sortedCounts(counts map[string]int) []htmlCount {
+	sorted := make([]htmlCount, 0, len(counts))
+	for name, count := range counts {
+		sorted = append(sorted, htmlCount{Name: name, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTMLFormatter(t *testing.T) {
+	rule := secrets.StaticRule{Name: "AWS", ID: "aws", Pattern: regexp.MustCompile(`AKIA[0-9A-Z]{16}`)}
+	info := ScanInfo{Image: "alpine:latest", Digest: "sha256:abc", Rules: []secrets.Rule{rule}, Time: time.Now()}
+	findings := []Finding{
+		{
+			Rule: rule, Source: File, Path: "app/<key>.txt", Line: 2, Secret: "AKI********PLE",
+			Layer: "sha256:layer", CreatedBy: "COPY . /app", Status: Deleted,
+			Context: &secrets.Snippet{StartLine: 1, Lines: []string{"# keys", "AKI********PLE"}},
+		},
+		{Rule: rule, Source: EnvVar, Variable: "AWS_KEY", Secret: "AKI********PLE"},
+	}
+
+	output, err := HTMLFormatter(info)(findings)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	for _, expected := range []string{"alpine:latest", "sha256:abc", "sha256:layer", "COPY . /app", "app/&lt;key&gt;.txt", "2 | AKI********PLE"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q", expected)
+		}
+	}
+	if strings.Contains(output, "<script src") || strings.Contains(output, "<link") {
+		t.Errorf("Expected output to not load external resources")
+	}
+}
+
+func TestHTMLFormatterImages(t *testing.T) {
+	rule := secrets.StaticRule{Name: "AWS", ID: "aws"}
+	info := ScanInfo{Image: "alpine:3.18, alpine:3.19", Rules: []secrets.Rule{rule}}
+	findings := []Finding{
+		{Rule: rule, Source: EnvVar, Variable: "AWS_KEY", Image: "alpine:3.18"},
+		{Rule: rule, Source: EnvVar, Variable: "AWS_KEY", Image: "alpine:3.19"},
+	}
+
+	output, err := HTMLFormatter(info)(findings)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	for _, expected := range []string{"<th>Image</th>", "<td><code>alpine:3.18</code></td>", "<td><code>alpine:3.19</code></td>"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q", expected)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package analysis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr,omitempty"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// JUnitFormatter will return a Formatter that outputs the findings as a JUnit XML
+// report. Each source searched by the scan is a test suite with a test case for each
+// static rule, except files, which have a test suite for each dynamic rule. Each finding
+// is a failed test case, and rules without any findings are a passed test case.
+// Findings of a comparison between images that were not added to the newer image
+// are a skipped test case, since they do not fail the scan.
+func JUnitFormatter(info ScanInfo) Formatter {
+	return func(findings []Finding) (string, error) {
+		var (
+			staticRules, dynamicRules []secrets.Rule
+			seen                      = make(map[string]bool)
+		)
+		addRule := func(r secrets.Rule) {
+			if seen[r.RuleID()] {
+				return
+			}
+			seen[r.RuleID()] = true
+			if _, ok := r.(secrets.DynamicRule); ok {
+				dynamicRules = append(dynamicRules, r)
+			} else {
+				staticRules = append(staticRules, r)
+			}
+		}
+		for _, r := range info.Rules {
+			addRule(r)
+		}
+
+		sources := info.Sources
+		searched := make(map[Source]bool)
+		for _, s := range sources {
+			searched[s] = true
+		}
+		for _, f := range findings {
+			addRule(f.Rule)
+			if !searched[f.Source] {
+				searched[f.Source] = true
+				sources = append(sources, f.Source)
+			}
+		}
+
+		report := junitTestSuites{Name: "dockerleaks " + info.Image}
+		for _, source := range sources {
+			if !source.searchesFiles() {
+				report.add(junitSuite(string(source), source, staticRules, findings))
+				continue
+			}
+			for _, r := range dynamicRules {
+				name := fmt.Sprintf("%s: %s", source, ruleName(r))
+				report.add(junitSuite(name, source, []secrets.Rule{r}, findings))
+			}
+		}
+
+		raw, err := xml.MarshalIndent(report, "", "  ")
+		return xml.Header + string(raw), err
+	}
+}
+
+// add will add the test suite to the report
+func (j *junitTestSuites) add(suite junitTestSuite) {
+	j.Suites = append(j.Suites, suite)
+	j.Tests += suite.Tests
+	j.Failures += suite.Failures
+}
+
+// junitSuite will return a test suite of the rules for the source, with a failed test
+// case for each finding of the rules and a passed test case for each rule without findings
+func junitSuite(name string, source Source, rules []secrets.Rule, findings []Finding) junitTestSuite {
+	suite := junitTestSuite{Name: name}
+	className := fmt.Sprintf("dockerleaks.%s", source)
+	for _, r := range rules {
+		found := false
+		for _, f := range findings {
+			if f.Source != source || f.Rule.RuleID() != r.RuleID() {
+				continue
+			}
+			found = true
+			testCase := junitTestCase{
+				Name:      fmt.Sprintf("%s in %s", ruleName(r), f.Location()),
+				ClassName: className,
+			}
+			if f.Change == Removed || f.Change == Unchanged {
+				suite.Skipped++
+				testCase.Skipped = &junitSkipped{Message: fmt.Sprintf("%s, %s", junitFailureMessage(f), f.Change)}
+			} else {
+				suite.Failures++
+				testCase.Failure = &junitFailure{
+					Message: junitFailureMessage(f),
+					Type:    r.RuleID(),
+					Text:    f.String(),
+				}
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		if !found {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      junitRuleName(r),
+				ClassName: className,
+			})
+		}
+	}
+	suite.Tests = len(suite.Cases)
+	return suite
+}
+
+// junitFailureMessage will describe the finding for the message of a failed test case
+func junitFailureMessage(f Finding) string {
+	if f.Secret == "" {
+		return fmt.Sprintf("%s found in %s %s", ruleName(f.Rule), f.Source, f.Location())
+	}
+	return fmt.Sprintf("%s secret %s found in %s %s", ruleName(f.Rule), f.Secret, f.Source, f.Location())
+}
+
+// junitRuleName will return the name of the test case of a rule without findings,
+// including the ID of the rule since rules commonly share a name
+func junitRuleName(r secrets.Rule) string {
+	if name := ruleName(r); name != r.RuleID() {
+		return fmt.Sprintf("%s [%s]", name, r.RuleID())
+	}
+	return r.RuleID()
+}
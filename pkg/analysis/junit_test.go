@@ -0,0 +1,52 @@
+package analysis
+
+import (
+	"encoding/xml"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestJUnitFormatter(t *testing.T) {
+	static := secrets.StaticRule{Name: "AWS", ID: "aws", Pattern: regexp.MustCompile(`AKIA[0-9A-Z]{16}`)}
+	other := secrets.StaticRule{Name: "Slack", ID: "slack", Pattern: regexp.MustCompile(`xoxb-[0-9]+`)}
+	dynamic := secrets.DynamicRule{Name: ".env file", ID: "env", FilePattern: regexp.MustCompile(`\.env$`)}
+	findings := []Finding{
+		{Rule: static, Source: EnvVar, Variable: "AWS_KEY", Secret: "AKI********PLE"},
+		{Rule: dynamic, Source: File, Path: "app/.env"},
+	}
+
+	output, err := JUnitFormatter(ScanInfo{
+		Image:   "alpine:latest",
+		Rules:   []secrets.Rule{static, other, dynamic},
+		Sources: []Source{EnvVar, BuildArgument, File},
+	})(findings)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	var report junitTestSuites
+	if err = xml.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("Expected valid XML, got %s", err)
+	}
+	if report.Tests != 5 || report.Failures != 2 {
+		t.Errorf("Expected 5 tests and 2 failures, got %d and %d", report.Tests, report.Failures)
+	}
+
+	var names []string
+	for _, s := range report.Suites {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "env-var,build-arg,file: .env file" {
+		t.Errorf("Expected a suite for each variable source and file rule, got %v", names)
+	}
+
+	failure := report.Suites[0].Cases[0].Failure
+	if failure == nil || !strings.Contains(failure.Message, "AKI********PLE") || failure.Type != "aws" {
+		t.Errorf("Expected failure with masked secret of rule aws, got %+v", failure)
+	}
+	if report.Suites[1].Failures != 0 || report.Suites[1].Tests != 2 {
+		t.Errorf("Expected passing cases for each rule of a clean source, got %+v", report.Suites[1])
+	}
+}
@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"archive/tar"
+	"github.com/bthuilot/dockerleaks/pkg/image"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"github.com/sirupsen/logrus"
+	"io"
+)
+
+// Layers will search the files of each layer of the image in order,
+// including files that are deleted or overwritten by later layers.
+// Each finding records the layer it was found in and whether the file
+// is still visible in the final image. Layers found in the cache are not searched again.
+func Layers(img image.Image, detector secrets.Detector, cache *LayerCache) ([]Finding, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		findings []Finding
+		// findingLayers is the index of the layer each finding was found in
+		findingLayers []int
+		merger        = image.NewMerger(layers)
+	)
+	for idx, l := range layers {
+		layerFindings, ok := cache.load(idx, l, merger)
+		if !ok {
+			logrus.Infof("searching layer %s", l.Digest())
+			suppressed := detector.Suppressed()
+			var (
+				entries []layerEntry
+				digest  string
+			)
+			layerFindings, entries, digest, err = searchLayer(idx, l, detector, merger)
+			if err != nil {
+				return nil, err
+			}
+			cache.save(l, digest, entries, layerFindings, detector.Suppressed()-suppressed)
+		}
+		for _, f := range layerFindings {
+			f.Layer = l.Digest()
+			f.CreatedBy = l.CreatedBy()
+			findings = append(findings, f)
+			findingLayers = append(findingLayers, idx)
+		}
+	}
+
+	// resolve the status of each finding now that all layers are merged
+	for i := range findings {
+		if p, ok := merger.Provenance(findings[i].Path, findingLayers[i]); ok {
+			findings[i].Status = fileStatus(p)
+		}
+	}
+	return findings, nil
+}
+
+// searchLayer will search each file of the layer for secrets,
+// applying each entry of the layer to the merger and returning the entries applied
+// and the digest of the content of the layer
+func searchLayer(idx int, l image.Layer, detector secrets.Detector, merger *image.Merger) ([]Finding, []layerEntry, string, error) {
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer rc.Close()
+
+	var (
+		findings []Finding
+		entries  []layerEntry
+		content  = newDigestReader(rc)
+		fs       = tar.NewReader(content)
+	)
+	for {
+		hdr, err := fs.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logrus.Errorf("error reading layer: %s", err)
+			return nil, nil, "", err
+		}
+
+		entries = append(entries, layerEntry{Name: hdr.Name, Type: hdr.Typeflag})
+		name, whiteout := merger.Apply(idx, hdr)
+		if whiteout {
+			continue
+		}
+
+		logrus.Debugf("checking file %s", name)
+		matches, err := detector.SearchFile(name, hdr.Size, io.LimitReader(fs, hdr.Size))
+		if err != nil {
+			return nil, nil, "", err
+		}
+		findings = append(findings, fileFindings(matches, File)...)
+	}
+	digest, err := content.digest()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return findings, entries, digest, nil
+}
+
+// fileStatus will return the status of a file from its provenance
+func fileStatus(p image.Provenance) FileStatus {
+	switch p.Removal {
+	case image.Overwritten:
+		return Shadowed
+	case image.WhitedOut:
+		return Deleted
+	default:
+		return Visible
+	}
+}
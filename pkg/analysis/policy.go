@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"strings"
+)
+
+// FailPolicy decides which findings fail a scan. A finding fails the scan if its
+// source is one of Sources and its rule is at least MinSeverity severe, where
+// no Sources means any source and no MinSeverity means any severity
+type FailPolicy struct {
+	// Sources are the sources of findings that fail the scan
+	Sources []Source
+	// MinSeverity is the minimum severity of findings that fail the scan
+	MinSeverity secrets.Severity
+}
+
+// ParseFailPolicy will parse a FailPolicy from a list of source names and severities,
+// where each severity is the minimum severity of a finding failing the scan
+func ParseFailPolicy(values []string) (FailPolicy, error) {
+	var policy FailPolicy
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if source, ok := parseSource(v); ok {
+			policy.Sources = append(policy.Sources, source)
+			continue
+		}
+		severity, err := secrets.ParseSeverity(v)
+		if err != nil || v == "" {
+			return policy, fmt.Errorf("invalid fail-on value '%s', must be a source or a severity", v)
+		}
+		if policy.MinSeverity != "" && policy.MinSeverity != severity {
+			return policy, fmt.Errorf("only one severity may be given, got '%s' and '%s'", policy.MinSeverity, severity)
+		}
+		policy.MinSeverity = severity
+	}
+	return policy, nil
+}
+
+// Fails will return true if the finding fails the scan. Findings of a comparison
+// between images only fail the scan if they were added to the newer image
+func (p FailPolicy) Fails(f Finding) bool {
+	if f.Change == Removed || f.Change == Unchanged {
+		return false
+	}
+	if p.MinSeverity != "" && !f.Rule.RuleSeverity().AtLeast(p.MinSeverity) {
+		return false
+	}
+	if len(p.Sources) == 0 {
+		return true
+	}
+	for _, s := range p.Sources {
+		if f.Source == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Failing will return the findings that fail the scan
+func (p FailPolicy) Failing(findings []Finding) []Finding {
+	var failing []Finding
+	for _, f := range findings {
+		if p.Fails(f) {
+			failing = append(failing, f)
+		}
+	}
+	return failing
+}
+
+// parseSource will return the source with the given name, if it exists
+func parseSource(name string) (Source, bool) {
+	for _, sources := range [][]Source{StaticSources, FileSources, RuntimeSources} {
+		for _, s := range sources {
+			if string(s) == name {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"testing"
+)
+
+func TestFailPolicy(t *testing.T) {
+	var (
+		critical = secrets.DynamicRule{Name: "AWS credentials", Severity: secrets.Critical}
+		medium   = secrets.StaticRule{Name: "Generic"}
+	)
+	tests := []struct {
+		failOn   []string
+		finding  Finding
+		expected bool
+	}{
+		{nil, Finding{Rule: medium, Source: BuildArgument}, true},
+		{[]string{"file"}, Finding{Rule: medium, Source: BuildArgument}, false},
+		{[]string{"file"}, Finding{Rule: medium, Source: File}, true},
+		{[]string{"high"}, Finding{Rule: medium, Source: File}, false},
+		{[]string{"HIGH"}, Finding{Rule: critical, Source: File}, true},
+		{[]string{"file", "env-var", "critical"}, Finding{Rule: critical, Source: EnvVar}, true},
+		{[]string{"file", "critical"}, Finding{Rule: critical, Source: BuildArgument}, false},
+	}
+	for _, tt := range tests {
+		policy, err := ParseFailPolicy(tt.failOn)
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if fails := policy.Fails(tt.finding); fails != tt.expected {
+			t.Errorf("Expected %v for %v failing on %v, got %v", tt.expected, tt.finding.Source, tt.failOn, fails)
+		}
+	}
+
+	for _, invalid := range [][]string{{"bogus"}, {""}, {"low", "high"}} {
+		if _, err := ParseFailPolicy(invalid); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+}
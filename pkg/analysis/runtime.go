@@ -0,0 +1,186 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/image"
+	"github.com/bthuilot/dockerleaks/pkg/image/container"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// procMarker separates the contents of each file read from /proc
+const procMarker = "==dockerleaks-proc=="
+
+// procScript prints the environment and command line of each process in the
+// container (other than the shell running the script), each prefixed by a
+// line containing the marker, the path of the process and the name of the file
+var procScript = fmt.Sprintf(`for p in /proc/[0-9]*; do
+  [ "$p" = "/proc/$$" ] && continue
+  for f in environ cmdline; do
+    printf '\n%[1]s %%s %%s\n' "$p" "$f"
+    cat "$p/$f" 2>/dev/null
+  done
+done`, procMarker)
+
+// ignoredRuntimePaths are the prefixes of paths changed at runtime that are not searched
+var ignoredRuntimePaths = []string{"/proc/", "/sys/", "/dev/"}
+
+// RuntimeOpts is used to configure a runtime analysis
+type RuntimeOpts struct {
+	// Container are the options for the started container
+	Container image.ContainerOpts
+	// Ready is the condition the container must meet before it is inspected
+	Ready container.ReadyCondition
+}
+
+// Runtime will start a container from the image in a sandbox and, once ready,
+// search the environment and command line of each running process and each
+// file written by the container since it was started for secrets, until the context is cancelled
+func Runtime(ctx context.Context, img image.Image, detector secrets.Detector, opts RuntimeOpts) ([]Finding, error) {
+	logrus.Infof("starting container from image")
+	opts.Container.Start = true
+	c, err := img.CreateContainer(opts.Container)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if destroyErr := img.DestroyContainer(c); destroyErr != nil {
+			logrus.Errorf("failure destroying container: %s", destroyErr)
+		}
+	}()
+
+	logrus.Infof("waiting for container to be ready")
+	if err = c.WaitReady(opts.Ready); err != nil {
+		return nil, err
+	}
+
+	findings, err := searchProcesses(ctx, c, detector)
+	if err != nil {
+		return nil, err
+	}
+
+	fileFindings, err := searchRuntimeFiles(ctx, c, detector)
+	if err != nil {
+		return nil, err
+	}
+	return append(findings, fileFindings...), nil
+}
+
+// searchProcesses will search the environment and command line
+// of each process running in the container for secrets
+func searchProcesses(ctx context.Context, c container.Container, detector secrets.Detector) ([]Finding, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	logrus.Infof("inspecting container processes")
+	output, err := c.RunCommand(procScript)
+	if err != nil {
+		// images without a shell cannot be inspected
+		logrus.Warnf("unable to inspect container processes: %s", err)
+		return nil, nil
+	}
+
+	var (
+		findings []Finding
+		seen     = make(map[string]bool)
+	)
+	for _, section := range strings.Split(output, "\n"+procMarker+" ")[1:] {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		header, content, _ := strings.Cut(section, "\n")
+		proc, file, _ := strings.Cut(header, " ")
+		path := fmt.Sprintf("%s/%s", proc, file)
+
+		var (
+			values []image.EnvVar
+			source Source
+		)
+		switch file {
+		case "environ":
+			source = ProcessEnv
+			for _, env := range strings.Split(content, "\x00") {
+				if name, value, ok := strings.Cut(env, "="); ok {
+					values = append(values, image.EnvVar{Name: name, Value: value})
+				}
+			}
+		case "cmdline":
+			source = ProcessCmdline
+			values = []image.EnvVar{{Value: strings.TrimSpace(strings.ReplaceAll(content, "\x00", " "))}}
+		}
+
+		for _, v := range values {
+			// processes commonly share the same environment
+			key := fmt.Sprintf("%s:%s=%s", source, v.Name, v.Value)
+			if v.Value == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			matches, err := detector.SearchVariable(v.Name, v.Value)
+			if err != nil {
+				return nil, err
+			}
+			// process IDs change between scans, so only the variable or file is fingerprinted
+			location := file
+			if v.Name != "" {
+				location = v.Name
+			}
+			for _, m := range matches {
+				findings = append(findings, Finding{
+					Secret:      m.Secret.String(),
+					Rule:        m.Rule,
+					Source:      source,
+					Path:        path,
+					Variable:    v.Name,
+					Fingerprint: fingerprint(m.Rule, source, location, m.Secret),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// searchRuntimeFiles will search each file added or modified
+// in the container since it was started for secrets
+func searchRuntimeFiles(ctx context.Context, c container.Container, detector secrets.Detector) ([]Finding, error) {
+	logrus.Infof("inspecting files changed at runtime")
+	paths, err := c.Changes()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, p := range paths {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if isIgnoredRuntimePath(p) {
+			continue
+		}
+		rc, size, err := c.CopyFile(p)
+		if err != nil {
+			logrus.Debugf("skipping %s: %s", p, err)
+			continue
+		}
+		logrus.Debugf("checking file %s", p)
+		matches, err := detector.SearchFile(p, size, rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, fileFindings(matches, RuntimeFile)...)
+	}
+	return findings, nil
+}
+
+// isIgnoredRuntimePath will return true if the path is a pseudo filesystem
+func isIgnoredRuntimePath(p string) bool {
+	for _, prefix := range ignoredRuntimePaths {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
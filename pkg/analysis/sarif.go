@@ -0,0 +1,224 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"strings"
+)
+
+const (
+	// sarifVersion is the version of the SARIF specification of the output
+	sarifVersion = "2.1.0"
+	// sarifSchema is the JSON schema of the SARIF specification of the output
+	sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifFingerprintKey is the key of the partial fingerprint of each result
+	sarifFingerprintKey = "dockerleaksFingerprint/v1"
+	// toolURI is the homepage of the project
+	toolURI = "https://github.com/bthuilot/dockerleaks"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string               `json:"id"`
+	Name             string               `json:"name,omitempty"`
+	ShortDescription sarifMessage         `json:"shortDescription"`
+	Properties       *sarifRuleProperties `json:"properties,omitempty"`
+}
+
+type sarifRuleProperties struct {
+	Tags []string `json:"tags,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string                `json:"ruleId"`
+	RuleIndex           int                   `json:"ruleIndex"`
+	Level               string                `json:"level"`
+	Message             sarifMessage          `json:"message"`
+	Locations           []sarifLocation       `json:"locations"`
+	PartialFingerprints map[string]string     `json:"partialFingerprints"`
+	BaselineState       string                `json:"baselineState,omitempty"`
+	Properties          sarifResultProperties `json:"properties"`
+}
+
+type sarifResultProperties struct {
+	Source    Source     `json:"source"`
+	Secret    string     `json:"secret,omitempty"`
+	Layer     string     `json:"layer,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	Status    FileStatus `json:"status,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// SARIFFormatter will return a Formatter that outputs the findings as a
+// [SARIF 2.1.0] log, with a rule for each of the rules of the scan
+//
+// [SARIF 2.1.0]: https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+func SARIFFormatter(info ScanInfo) Formatter {
+	return func(findings []Finding) (string, error) {
+		var (
+			rules   []sarifRule
+			indexes = make(map[string]int)
+		)
+		addRule := func(r secrets.Rule) int {
+			id := r.RuleID()
+			if idx, ok := indexes[id]; ok {
+				return idx
+			}
+			indexes[id] = len(rules)
+			rules = append(rules, newSARIFRule(r))
+			return indexes[id]
+		}
+		for _, r := range info.Rules {
+			addRule(r)
+		}
+
+		results := make([]sarifResult, 0, len(findings))
+		for _, f := range findings {
+			result := sarifResult{
+				RuleID:    f.Rule.RuleID(),
+				RuleIndex: addRule(f.Rule),
+				Level:     sarifLevel(f.Rule.RuleSeverity()),
+				Message:   sarifMessage{Text: sarifResultMessage(f)},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: artifactLocation(info, f)},
+					},
+				}},
+				PartialFingerprints: map[string]string{sarifFingerprintKey: f.Fingerprint},
+				BaselineState:       sarifBaselineStates[f.Change],
+				Properties: sarifResultProperties{
+					Source:    f.Source,
+					Secret:    f.Secret,
+					Layer:     f.Layer,
+					CreatedBy: f.CreatedBy,
+					Status:    f.Status,
+				},
+			}
+			if f.Line > 0 {
+				result.Locations[0].PhysicalLocation.Region = &sarifRegion{
+					StartLine:   f.Line,
+					StartColumn: f.Column,
+				}
+			}
+			results = append(results, result)
+		}
+
+		log := sarifLog{
+			Version: sarifVersion,
+			Schema:  sarifSchema,
+			Runs: []sarifRun{{
+				Tool: sarifTool{Driver: sarifDriver{
+					Name:           "dockerleaks",
+					InformationURI: toolURI,
+					Rules:          rules,
+				}},
+				Results: results,
+			}},
+		}
+		raw, err := json.MarshalIndent(log, "", "  ")
+		return string(raw), err
+	}
+}
+
+// newSARIFRule will describe the rule as a SARIF reporting descriptor
+func newSARIFRule(r secrets.Rule) sarifRule {
+	rule := sarifRule{
+		ID:               r.RuleID(),
+		ShortDescription: sarifMessage{Text: r.String()},
+	}
+	rule.Name = ruleName(r)
+	var tags []string
+	switch typed := r.(type) {
+	case secrets.StaticRule:
+		tags = typed.Tags
+	case secrets.DynamicRule:
+		tags = typed.Tags
+	}
+	// rules without tags have no properties
+	if len(tags) > 0 {
+		rule.Properties = &sarifRuleProperties{Tags: tags}
+	}
+	return rule
+}
+
+// sarifResultMessage will describe the finding for the message of a SARIF result
+func sarifResultMessage(f Finding) string {
+	return fmt.Sprintf("%s secret found in %s %s", ruleName(f.Rule), f.Source, f.Location())
+}
+
+// artifactLocation will return the location of the finding, as 'env:NAME'
+// or 'build-arg:NAME' for variables and 'image@digest:/path' for files
+func artifactLocation(info ScanInfo, f Finding) string {
+	switch f.Source {
+	case EnvVar:
+		return "env:" + f.Variable
+	case BuildArgument:
+		return "build-arg:" + f.Variable
+	}
+	image := imageRef(info.Image, info.Digest)
+	if f.Image != "" {
+		image = imageRef(f.Image, f.Digest)
+	}
+	return fmt.Sprintf("%s:/%s", image, strings.TrimPrefix(f.Path, "/"))
+}
+
+// sarifBaselineStates are the SARIF baseline states of each change of a finding
+var sarifBaselineStates = map[Change]string{
+	Added:     "new",
+	Removed:   "absent",
+	Unchanged: "unchanged",
+}
+
+// sarifLevel will return the SARIF level of a result of a rule with the severity
+func sarifLevel(severity secrets.Severity) string {
+	switch severity {
+	case secrets.Low:
+		return "note"
+	case secrets.Medium:
+		return "warning"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"encoding/json"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"regexp"
+	"testing"
+)
+
+func TestSARIFFormatter(t *testing.T) {
+	static := secrets.StaticRule{Name: "AWS", ID: "aws", Tags: []string{"cloud"}, Pattern: regexp.MustCompile(`AKIA[0-9A-Z]{16}`)}
+	dynamic := secrets.DynamicRule{Name: ".env file", FilePattern: regexp.MustCompile(`\.env$`)}
+	findings := []Finding{
+		{Rule: static, Source: EnvVar, Variable: "AWS_KEY", Fingerprint: "a"},
+		{Rule: static, Source: File, Path: "app/key.txt", Line: 3, Column: 5, Fingerprint: "b"},
+		{Rule: dynamic, Source: File, Path: "/app/.env", Fingerprint: "c"},
+	}
+
+	output, err := SARIFFormatter(ScanInfo{
+		Image:  "alpine:latest",
+		Digest: "sha256:abc",
+		Rules:  []secrets.Rule{static},
+	})(findings)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	var log sarifLog
+	if err = json.Unmarshal([]byte(output), &log); err != nil {
+		t.Fatalf("Expected valid JSON, got %s", err)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(run.Tool.Driver.Rules))
+	}
+	for _, rule := range run.Tool.Driver.Rules {
+		switch {
+		case rule.ID == static.RuleID() && (rule.Properties == nil || len(rule.Properties.Tags) != 1):
+			t.Errorf("Expected tags of rule %s, got %v", rule.ID, rule.Properties)
+		case rule.ID != static.RuleID() && rule.Properties != nil:
+			t.Errorf("Expected no properties for rule %s without tags, got %v", rule.ID, rule.Properties)
+		}
+	}
+
+	expected := []struct {
+		uri         string
+		fingerprint string
+	}{
+		{"env:AWS_KEY", "a"},
+		{"alpine:latest@sha256:abc:/app/key.txt", "b"},
+		{"alpine:latest@sha256:abc:/app/.env", "c"},
+	}
+	for i, r := range run.Results {
+		if rule := run.Tool.Driver.Rules[r.RuleIndex]; rule.ID != r.RuleID {
+			t.Errorf("Expected rule index of %s, got %s", r.RuleID, rule.ID)
+		}
+		if uri := r.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != expected[i].uri {
+			t.Errorf("Expected location %s, got %s", expected[i].uri, uri)
+		}
+		if fp := r.PartialFingerprints[sarifFingerprintKey]; fp != expected[i].fingerprint {
+			t.Errorf("Expected fingerprint %s, got %s", expected[i].fingerprint, fp)
+		}
+	}
+	if region := run.Results[1].Locations[0].PhysicalLocation.Region; region == nil || region.StartLine != 3 {
+		t.Errorf("Expected region at line 3, got %v", region)
+	}
+}
@@ -0,0 +1,195 @@
+package analysis
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/bthuilot/dockerleaks/pkg/image"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sort"
+	"sync"
+)
+
+// maxBufferedFileSize is the size of the largest file read into memory before
+// being searched. Larger files are streamed to a worker, blocking the reader
+// until the file is searched, so memory use stays bounded by the amount of workers
+const maxBufferedFileSize = 4 * 1024 * 1024
+
+// fileJob is a file read from a tarball to be searched by a worker
+type fileJob struct {
+	// name is the path of the file
+	name string
+	// size is the size of the file in bytes
+	size int64
+	// body is the content of the file
+	body io.Reader
+}
+
+// scanner searches the files of tarballs for secrets, with one goroutine reading
+// each tarball and handing its files to a pool of workers searching them
+type scanner struct {
+	parent   context.Context
+	ctx      context.Context
+	cancel   context.CancelFunc
+	detector secrets.Detector
+	jobs     chan fileJob
+	workers  sync.WaitGroup
+
+	// mu guards findings and err
+	mu       sync.Mutex
+	findings []Finding
+	err      error
+}
+
+// newScanner will start a new scanner with the given amount of workers,
+// which must be stopped with wait once all tarballs are searched
+func newScanner(ctx context.Context, detector secrets.Detector, workers int) *scanner {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &scanner{
+		parent:   ctx,
+		detector: detector,
+		jobs:     make(chan fileJob),
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	for i := 0; i < workers; i++ {
+		s.workers.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// work will search each file handed to the worker until there are no more files
+func (s *scanner) work() {
+	defer s.workers.Done()
+	for job := range s.jobs {
+		if s.ctx.Err() != nil {
+			// drain the remaining files so the reader is never blocked
+			closeJob(job, s.ctx.Err())
+			continue
+		}
+		logrus.Debugf("checking file %s", job.name)
+		matches, err := s.detector.SearchFile(job.name, job.size, job.body)
+		closeJob(job, err)
+		if err != nil {
+			s.fail(err)
+			continue
+		}
+
+		s.mu.Lock()
+		s.findings = append(s.findings, fileFindings(matches, File)...)
+		s.mu.Unlock()
+	}
+}
+
+// closeJob will unblock the reader of a streamed file, if the file was not fully read
+func closeJob(job fileJob, err error) {
+	if pr, ok := job.body.(*io.PipeReader); ok {
+		_ = pr.CloseWithError(err)
+	}
+}
+
+// fail will record the first error of the scan and cancel the scan
+func (s *scanner) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.cancel()
+}
+
+// searchTar will hand each file in the tarball accepted by the filter to the
+// workers, returning once every file is handed or the scan is cancelled
+func (s *scanner) searchTar(fs *tar.Reader, filter func(*tar.Header) bool) error {
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := fs.Next()
+		if err == io.EOF {
+			logrus.Debugf("end of filesystem")
+			return nil
+		}
+		if err != nil {
+			logrus.Errorf("error reading filesystem: %s", err)
+			s.fail(err)
+			return err
+		}
+		if !filter(hdr) {
+			continue
+		}
+		// paths are normalized as in the layers analysis, since both cache the findings of a layer
+		name := image.CleanPath(hdr.Name)
+
+		if hdr.Size <= maxBufferedFileSize {
+			content, err := io.ReadAll(io.LimitReader(fs, hdr.Size))
+			if err != nil {
+				logrus.Errorf("error reading file %s: %s", hdr.Name, err)
+				s.fail(err)
+				return err
+			}
+			if err = s.send(fileJob{name: name, size: hdr.Size, body: bytes.NewReader(content)}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		pr, pw := io.Pipe()
+		if err = s.send(fileJob{name: name, size: hdr.Size, body: pr}); err != nil {
+			return err
+		}
+		_, err = io.Copy(pw, io.LimitReader(fs, hdr.Size))
+		_ = pw.CloseWithError(err)
+		// the worker closes the pipe early if it does not need the rest of the file
+		if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			logrus.Errorf("error reading file %s: %s", hdr.Name, err)
+			s.fail(err)
+			return err
+		}
+	}
+}
+
+// send will hand the file to a worker, unless the scan is cancelled
+func (s *scanner) send(job fileJob) error {
+	select {
+	case s.jobs <- job:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// wait will stop the workers once they have searched every file, returning
+// the findings sorted by path or the first error encountered
+func (s *scanner) wait() ([]Finding, error) {
+	close(s.jobs)
+	s.workers.Wait()
+	s.cancel()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err := s.parent.Err(); err != nil {
+		return nil, err
+	}
+	sortFindings(s.findings)
+	return s.findings, nil
+}
+
+// sortFindings will sort the findings by path, and then by rule and secret,
+// so that the order does not depend on the order files were searched in
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		if ri, rj := findings[i].Rule.String(), findings[j].Rule.String(); ri != rj {
+			return ri < rj
+		}
+		return findings[i].Secret < findings[j].Secret
+	})
+}
@@ -20,15 +20,17 @@ func Static(img image.Image, detector secrets.Detector) (findings []Finding, err
 
 	for _, v := range envVars {
 		logrus.Debugf("searching for secrets in env var %s", v.Name)
-		matches, err = detector.SearchText(v.Value)
+		matches, err = detector.SearchVariable(v.Name, v.Value)
 		if err != nil {
 			return nil, err
 		}
 		for _, m := range matches {
 			findings = append(findings, Finding{
-				Secret: m.Secret.String(),
-				Rule:   m.Rule,
-				Source: EnvVar,
+				Secret:      m.Secret.String(),
+				Rule:        m.Rule,
+				Source:      EnvVar,
+				Variable:    v.Name,
+				Fingerprint: fingerprint(m.Rule, EnvVar, v.Name, m.Secret),
 			})
 		}
 	}
@@ -39,15 +41,17 @@ func Static(img image.Image, detector secrets.Detector) (findings []Finding, err
 	}
 
 	for _, v := range buildArgs {
-		matches, err = detector.SearchText(v.Value)
+		matches, err = detector.SearchVariable(v.Name, v.Value)
 		if err != nil {
 			return nil, err
 		}
 		for _, m := range matches {
 			findings = append(findings, Finding{
-				Secret: m.Secret.String(),
-				Rule:   m.Rule,
-				Source: BuildArgument,
+				Secret:      m.Secret.String(),
+				Rule:        m.Rule,
+				Source:      BuildArgument,
+				Variable:    v.Name,
+				Fingerprint: fingerprint(m.Rule, BuildArgument, v.Name, m.Secret),
 			})
 		}
 	}
@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// templates are the example templates shipped with the application
+//
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// TemplateData is the data a template is executed with
+type TemplateData struct {
+	ScanInfo
+	// Findings are the findings of the scan
+	Findings []Finding
+	// StaticRuleCount is the amount of static rules the image was scanned with
+	StaticRuleCount int
+	// DynamicRuleCount is the amount of dynamic rules the image was scanned with
+	DynamicRuleCount int
+}
+
+// templateFuncs are the helper functions available to templates
+var templateFuncs = template.FuncMap{
+	// mask will mask the value, unless secrets are unmasked. Masking the
+	// already masked secret of a finding returns it unchanged
+	"mask": func(value string) string {
+		return secrets.Secret{Value: value}.String()
+	},
+	"json": func(v any) (string, error) {
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(sep string, values []string) string {
+		return strings.Join(values, sep)
+	},
+	// csv will quote the value as a field of a CSV record, if required
+	"csv": func(v any) string {
+		value := fmt.Sprint(v)
+		if !strings.ContainsAny(value, ",\"\r\n") {
+			return value
+		}
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	},
+}
+
+// TemplateNames will return the names of the example templates shipped with the application
+func TemplateNames() []string {
+	entries, _ := fs.ReadDir(templates, "templates")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".tmpl"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseTemplate will parse the template file located at nameOrPath. If no such
+// file exists, the example template with the name is used (see [TemplateNames])
+func ParseTemplate(nameOrPath string) (*template.Template, error) {
+	raw, err := os.ReadFile(nameOrPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		raw, err = templates.ReadFile(path.Join("templates", nameOrPath+".tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("no template file or example template named '%s' (examples: %s)",
+				nameOrPath, strings.Join(TemplateNames(), ", "))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return template.New(path.Base(nameOrPath)).Funcs(templateFuncs).Parse(string(raw))
+}
+
+// TemplateFormatter will return a Formatter that executes the template with the
+// findings and information about the scan (see [TemplateData])
+func TemplateFormatter(info ScanInfo, tmpl *template.Template) Formatter {
+	return func(findings []Finding) (string, error) {
+		var b strings.Builder
+		err := tmpl.Execute(&b, newTemplateData(info, findings))
+		return b.String(), err
+	}
+}
+
+// newTemplateData will construct the data of a template from the scan and its findings
+func newTemplateData(info ScanInfo, findings []Finding) TemplateData {
+	data := TemplateData{ScanInfo: info, Findings: findings}
+	for _, r := range info.Rules {
+		if _, ok := r.(secrets.DynamicRule); ok {
+			data.DynamicRuleCount++
+		} else {
+			data.StaticRuleCount++
+		}
+	}
+	return data
+}
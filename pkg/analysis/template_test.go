@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"github.com/bthuilot/dockerleaks/pkg/secrets"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateFormatterExamples(t *testing.T) {
+	rule := secrets.StaticRule{Name: "AWS", ID: "aws", Pattern: regexp.MustCompile(`AKIA[0-9A-Z]{16}`)}
+	info := ScanInfo{Image: "alpine:latest", Rules: []secrets.Rule{rule}, Time: time.Now()}
+	findings := []Finding{{Rule: rule, Source: File, Path: "app/a,b.txt", Line: 2, Secret: "AKI********PLE"}}
+
+	for _, name := range TemplateNames() {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(name)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err)
+			}
+			output, err := TemplateFormatter(info, tmpl)(findings)
+			if err != nil {
+				t.Fatalf("Expected no error, got %s", err)
+			}
+			if !strings.Contains(output, "aws") || !strings.Contains(output, "AKI********PLE") {
+				t.Errorf("Expected output to contain the finding, got %s", output)
+			}
+		})
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	tmpl, err := ParseTemplate("csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err = tmpl.New("funcs").Parse(`{{ csv "a,\"b\"" }} {{ upper "x" }} {{ json .StaticRuleCount }} {{ mask "abcdefgh" }} {{ mask (mask "abcdefgh") }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := TemplateFormatter(ScanInfo{}, tmpl)(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"a,""b""" X 0 abc********fgh abc********fgh`; output != expected {
+		t.Errorf("Expected %s, got %s", expected, output)
+	}
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// tempPrefix is the prefix of files being written to the cache,
+	// which are renamed to their entry once fully written
+	tempPrefix = ".tmp-"
+	// staleTempAge is the age after which a temporary file is abandoned
+	// by the process writing it, and is removed when pruning
+	staleTempAge = time.Hour
+)
+
+// Cache is an on-disk key value store that is safe to share between concurrent processes.
+// Entries are written to a temporary file and atomically renamed, so readers never
+// see a partially written entry, and concurrent writers of the same key leave one complete entry.
+type Cache interface {
+	// Get will return the value of the entry with the given key, if it exists
+	Get(key string) ([]byte, bool)
+	// Put will store the value as the entry with the given key
+	Put(key string, value []byte) error
+	// Prune will remove every entry not used within maxAge, or every entry
+	// if maxAge is 0, returning the amount of entries removed
+	Prune(maxAge time.Duration) (int, error)
+	// Dir will return the directory the cache is stored in
+	Dir() string
+}
+
+// New will open the cache stored in dir, creating the directory if it does not exist
+func New(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		logrus.Errorf("error creating cache directory %s: %s", dir, err)
+		return nil, errors.New("unable to create cache directory")
+	}
+	return diskCache{dir: dir}, nil
+}
+
+// DefaultDir will return the default directory of the cache,
+// within the cache directory of the user
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dockerleaks"), nil
+}
+
+// diskCache is the concrete implementation of the Cache interface
+type diskCache struct {
+	dir string
+}
+
+func (c diskCache) Dir() string {
+	return c.dir
+}
+
+// path will return the path of the entry with the given key, where entries are
+// named by the hash of their key and split into directories by its first byte
+func (c diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+func (c diskCache) Get(key string) ([]byte, bool) {
+	p := c.path(key)
+	value, err := os.ReadFile(p)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logrus.Warnf("error reading cache entry %s: %s", p, err)
+		}
+		return nil, false
+	}
+	// the modification time records when the entry was last used, for pruning
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return value, true
+}
+
+func (c diskCache) Put(key string, value []byte) error {
+	p := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		logrus.Errorf("error creating cache directory %s: %s", filepath.Dir(p), err)
+		return errors.New("unable to write cache entry")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), tempPrefix)
+	if err != nil {
+		logrus.Errorf("error creating cache entry %s: %s", p, err)
+		return errors.New("unable to write cache entry")
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		logrus.Errorf("error writing cache entry %s: %s", p, err)
+		return errors.New("unable to write cache entry")
+	}
+	return nil
+}
+
+func (c diskCache) Prune(maxAge time.Duration) (int, error) {
+	var (
+		removed    int
+		now        = time.Now()
+		cutoff     = now.Add(-maxAge)
+		tempCutoff = now.Add(-staleTempAge)
+	)
+	err := filepath.WalkDir(c.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			// the entry was removed by another process
+			return nil
+		}
+		isTemp := strings.HasPrefix(d.Name(), tempPrefix)
+		switch {
+		case isTemp && info.ModTime().After(tempCutoff):
+			// the file may still be written by another process
+			return nil
+		case !isTemp && maxAge > 0 && info.ModTime().After(cutoff):
+			return nil
+		}
+		if err = os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if !isTemp {
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("error pruning cache %s: %s", c.dir, err)
+		return removed, errors.New("unable to prune cache")
+	}
+	return removed, nil
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c, err := New(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Errorf("Expected no entry for a missing key")
+	}
+	if err = c.Put("key", []byte("value")); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if err = c.Put("other", []byte("value")); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if value, ok := c.Get("key"); !ok || string(value) != "value" {
+		t.Errorf("Expected entry 'value', got '%s' (%t)", value, ok)
+	}
+
+	// age the entry of "other", which is unused since it was written
+	old := time.Now().Add(-48 * time.Hour)
+	if err = os.Chtimes(c.(diskCache).path("other"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := c.Prune(24 * time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 entry removed, got %d (%v)", removed, err)
+	}
+	if _, ok := c.Get("other"); ok {
+		t.Errorf("Expected the pruned entry to be removed")
+	}
+
+	removed, err = c.Prune(0)
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 entry removed, got %d (%v)", removed, err)
+	}
+	if _, ok := c.Get("key"); ok {
+		t.Errorf("Expected every entry to be removed")
+	}
+}
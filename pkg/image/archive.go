@@ -0,0 +1,170 @@
+package image
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// archiveManifestPath is the path of the manifest inside a `docker save` archive
+const archiveManifestPath = "manifest.json"
+
+// archiveManifest is a single entry of the manifest
+// written by `docker save` to the root of the archive
+type archiveManifest struct {
+	// Config is the path of the image configuration in the archive
+	Config string
+	// RepoTags are the tags the image was saved with
+	RepoTags []string
+	// Layers are the paths of the layer tarballs in the archive,
+	// ordered from the base layer to the top most layer
+	Layers []string
+}
+
+// archiveImage is an Image read from a `docker save` archive
+type archiveImage struct {
+	configImage
+	// path is the location of the archive on disk
+	path string
+}
+
+// NewArchiveImage will construct a new Image from a `docker save` archive located
+// at path. If the archive contains multiple images, tag is used to select the image,
+// otherwise the first image in the archive is used.
+func NewArchiveImage(path string, tag string) (Image, error) {
+	return newArchiveImage(path, tag)
+}
+
+func newArchiveImage(path string, tag string) (*archiveImage, error) {
+	var manifests []archiveManifest
+	if err := readArchiveJSON(path, archiveManifestPath, &manifests); err != nil {
+		logrus.Errorf("failure reading archive manifest: %s", err)
+		return nil, errors.New("invalid image archive")
+	}
+
+	manifest, err := selectArchiveManifest(manifests, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &archiveImage{path: path}
+	img.name, img.digest = archiveName(path, tag, manifest), archiveConfigDigest(manifest.Config)
+	if err = readArchiveJSON(path, manifest.Config, &img.config); err != nil {
+		logrus.Errorf("failure reading image configuration: %s", err)
+		return nil, errors.New("invalid image archive")
+	}
+
+	for idx, layerPath := range manifest.Layers {
+		layerPath := layerPath
+		img.layers = append(img.layers, layer{
+			digest:    img.diffID(idx, layerPath),
+			createdBy: img.createdBy(idx),
+			open: func() (io.ReadCloser, error) {
+				return openArchiveEntry(path, layerPath)
+			},
+		})
+	}
+	return img, nil
+}
+
+// selectArchiveManifest will return the manifest tagged with the given tag,
+// or the first manifest if tag is empty
+func selectArchiveManifest(manifests []archiveManifest, tag string) (archiveManifest, error) {
+	if len(manifests) == 0 {
+		return archiveManifest{}, errors.New("image archive contains no images")
+	}
+	if tag == "" {
+		if len(manifests) > 1 {
+			logrus.Warnf("image archive contains %d images, using the first", len(manifests))
+		}
+		return manifests[0], nil
+	}
+	for _, m := range manifests {
+		for _, t := range m.RepoTags {
+			if sameTag(t, tag) {
+				return m, nil
+			}
+		}
+	}
+	return archiveManifest{}, fmt.Errorf("image archive does not contain image '%s'", tag)
+}
+
+// archiveName will return the name of the image in the archive, which is the
+// tag it was selected by, the first tag it was saved with, or the name of the archive
+func archiveName(archivePath, tag string, manifest archiveManifest) string {
+	switch {
+	case tag != "":
+		return tag
+	case len(manifest.RepoTags) > 0:
+		return manifest.RepoTags[0]
+	default:
+		return filepath.Base(archivePath)
+	}
+}
+
+// archiveConfigDigest will return the digest of the image configuration from its path
+// in the archive, which is named by its sha256 digest (i.e. '<digest>.json' or
+// 'blobs/sha256/<digest>'). An empty string is returned if the path is not a digest
+func archiveConfigDigest(configPath string) string {
+	d := digest.NewDigestFromEncoded(digest.SHA256, strings.TrimSuffix(path.Base(configPath), ".json"))
+	if d.Validate() != nil {
+		return ""
+	}
+	return d.String()
+}
+
+// sameTag will return true if both image names refer to the same tag
+// once normalized (i.e. 'alpine' and 'docker.io/library/alpine:latest')
+func sameTag(a, b string) bool {
+	normalize := func(name string) string {
+		named, err := reference.ParseNormalizedNamed(name)
+		if err != nil {
+			return name
+		}
+		return reference.TagNameOnly(named).String()
+	}
+	return normalize(a) == normalize(b)
+}
+
+// readArchiveJSON will decode the JSON file at name inside the archive into v
+func readArchiveJSON(archivePath, name string, v any) error {
+	rc, err := openArchiveEntry(archivePath, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(v)
+}
+
+// openArchiveEntry will open the file at name inside the tar archive
+// located at archivePath. The caller is responsible for closing the reader
+func openArchiveEntry(archivePath, name string) (io.ReadCloser, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			_ = f.Close()
+			return nil, fmt.Errorf("file '%s' not found in archive", name)
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if path.Clean(hdr.Name) == path.Clean(name) {
+			return readCloser{Reader: tr, Closer: f}, nil
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testArchiveFile is a file of a tar archive
+type testArchiveFile struct {
+	name    string
+	content []byte
+}
+
+// writeTestTar will write a tar archive of the files, in order
+func writeTestTar(t *testing.T, w io.Writer, files []testArchiveFile) {
+	t.Helper()
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestArchive will write a `docker save` archive of two images to a temporary directory:
+// test/app:v1 with a base and a top layer, and test/app:v2 with only the base layer.
+// The path of the archive and the digests of the layers of test/app:v1 are returned
+func newTestArchive(t *testing.T) (string, []digest.Digest) {
+	t.Helper()
+	var base, top bytes.Buffer
+	writeTestTar(t, &base, []testArchiveFile{{"etc/app.conf", []byte("debug=false\n")}})
+	writeTestTar(t, &top, []testArchiveFile{{"app/.env", []byte("TOKEN=secret\n")}})
+	layers := []digest.Digest{digest.FromBytes(base.Bytes()), digest.FromBytes(top.Bytes())}
+
+	v1, _ := json.Marshal(ocispec.Image{
+		Config: ocispec.ImageConfig{Env: []string{"PATH=/bin", "API_KEY=abc123"}},
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: layers},
+		History: []ocispec.History{
+			{CreatedBy: "/bin/sh -c #(nop) COPY file:a in /etc/app.conf"},
+			{CreatedBy: "ARG TOKEN", EmptyLayer: true},
+			{CreatedBy: "|1 TOKEN=hunter2 /bin/sh -c echo $TOKEN > /app/.env"},
+		},
+	})
+	v2, _ := json.Marshal(ocispec.Image{
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: layers[:1]},
+	})
+	v1Config, v2Config := digest.FromBytes(v1).Encoded()+".json", digest.FromBytes(v2).Encoded()+".json"
+
+	manifest, _ := json.Marshal([]archiveManifest{
+		{Config: v1Config, RepoTags: []string{"test/app:v1"}, Layers: []string{"base/layer.tar", "top/layer.tar"}},
+		{Config: v2Config, RepoTags: []string{"test/app:v2"}, Layers: []string{"base/layer.tar"}},
+	})
+
+	path := filepath.Join(t.TempDir(), "image.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// the layers are stored in the reverse order they are applied in
+	writeTestTar(t, f, []testArchiveFile{
+		{"top/layer.tar", top.Bytes()},
+		{"base/layer.tar", base.Bytes()},
+		{v1Config, v1},
+		{v2Config, v2},
+		{archiveManifestPath, manifest},
+	})
+	return path, layers
+}
+
+func TestArchiveImage(t *testing.T) {
+	path, layerDigests := newTestArchive(t)
+	img, err := NewArchiveImage(path, "")
+	if err != nil {
+		t.Fatalf("unexpected error opening archive: %s", err)
+	}
+	if img.Name() != "test/app:v1" {
+		t.Errorf("expected the first image of the archive, got %s", img.Name())
+	}
+
+	envVars, err := img.ParseEnvVars()
+	if err != nil {
+		t.Fatalf("unexpected error parsing env vars: %s", err)
+	}
+	if len(envVars) != 2 || envVars[1].Name != "API_KEY" || envVars[1].Value != "abc123" {
+		t.Errorf("unexpected env vars %+v", envVars)
+	}
+
+	buildArgs, err := img.ParseBuildArguments()
+	if err != nil {
+		t.Fatalf("unexpected error parsing build args: %s", err)
+	}
+	if len(buildArgs) != 1 || buildArgs[0].Name != "TOKEN" || buildArgs[0].Value != "hunter2" {
+		t.Errorf("unexpected build args %+v", buildArgs)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		t.Fatalf("unexpected error reading layers: %s", err)
+	}
+	var testCases = []struct {
+		file      string
+		createdBy string
+	}{
+		{"etc/app.conf", "/bin/sh -c #(nop) COPY file:a in /etc/app.conf"},
+		// history entries of instructions without a layer are skipped
+		{"app/.env", "|1 TOKEN=hunter2 /bin/sh -c echo $TOKEN > /app/.env"},
+	}
+	if len(layers) != len(testCases) {
+		t.Fatalf("expected %d layers, got %d", len(testCases), len(layers))
+	}
+	for i, tc := range testCases {
+		l := layers[i]
+		if l.Digest() != layerDigests[i].String() || l.CreatedBy() != tc.createdBy {
+			t.Errorf("unexpected layer %d %s created by '%s'", i, l.Digest(), l.CreatedBy())
+		}
+		rc, err := l.Uncompressed()
+		if err != nil {
+			t.Fatalf("unexpected error opening layer %d: %s", i, err)
+		}
+		hdr, err := tar.NewReader(rc).Next()
+		_ = rc.Close()
+		if err != nil || hdr.Name != tc.file {
+			t.Errorf("expected layer %d to contain %s, got %v (%v)", i, tc.file, hdr, err)
+		}
+	}
+}
+
+func TestArchiveImageTag(t *testing.T) {
+	path, layerDigests := newTestArchive(t)
+	img, err := NewArchiveImage(path, "test/app:v2")
+	if err != nil {
+		t.Fatalf("unexpected error opening archive: %s", err)
+	}
+	layers, _ := img.Layers()
+	if img.Name() != "test/app:v2" || len(layers) != 1 || layers[0].Digest() != layerDigests[0].String() {
+		t.Errorf("expected the base layer of test/app:v2, got %d layers of %s", len(layers), img.Name())
+	}
+
+	if _, err = NewArchiveImage(path, "test/app:v3"); err == nil {
+		t.Errorf("expected an error for a tag not in the archive")
+	}
+}
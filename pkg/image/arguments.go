@@ -23,8 +23,23 @@ var argLineRegex = regexp.MustCompile(`ARG\s+([A-Za-z0-9_\-]+)`)
 // ParseBuildArguments will parse out each build argument by inspecting
 // each response item in the docker images history, collecting the current set shell
 // and build arguments, to be able to parse out each build arguments value
-func (i image) ParseBuildArguments() ([]BuildArg, error) {
+func (i *image) ParseBuildArguments() ([]BuildArg, error) {
 	history, err := i.cli.ImageHistory(i.ctx, i.ref.String())
+	if err != nil {
+		return nil, err
+	}
+	// the docker daemon returns the history with the most recent layer first
+	lines := make([]string, 0, len(history))
+	for _, h := range util.Reverse(history) {
+		lines = append(lines, h.CreatedBy)
+	}
+	return parseBuildArguments(lines), nil
+}
+
+// parseBuildArguments will parse out each build argument from the
+// 'created by' line of each history entry of an image, ordered from
+// the first layer to the last
+func parseBuildArguments(history []string) []BuildArg {
 	var (
 		// buildArgs is the list of build arguments discovered
 		buildArgs []BuildArg
@@ -35,25 +50,24 @@ func (i image) ParseBuildArguments() ([]BuildArg, error) {
 		// this is needed to be able to parse out the build arguments value from a run command
 		shell = "/bin/sh"
 	)
-	for _, h := range util.Reverse(history) {
-		logrus.Debugf("parsing history line %s", h.CreatedBy)
+	for _, createdBy := range history {
+		logrus.Debugf("parsing history line %s", createdBy)
 		// If this is a shell line
-		if matches := shellLineRegex.FindStringSubmatch(h.CreatedBy); matches != nil {
+		if matches := shellLineRegex.FindStringSubmatch(createdBy); matches != nil {
 			// set the shell to the new shell
 			logrus.Debugf("found match for SHELL line: %s", matches[1])
 			shell = matches[1]
 		}
 
-		if matches := argLineRegex.FindStringSubmatch(h.CreatedBy); matches != nil {
+		if matches := argLineRegex.FindStringSubmatch(createdBy); matches != nil {
 			// If this is a build arg line
 			// add the build arg to the current list of args
 			logrus.Debugf("found match for ARG line: %s", matches[1])
 			definedArgs = append(definedArgs, matches[1])
 		}
 
-		if matches := runLineRegex.FindStringSubmatch(h.CreatedBy); matches != nil {
-			amt := 0
-			if amt, err = strconv.Atoi(matches[1]); err != nil {
+		if matches := runLineRegex.FindStringSubmatch(createdBy); matches != nil {
+			if amt, err := strconv.Atoi(matches[1]); err != nil {
 				logrus.Warnf("invalid build arg amount %s, skipping", matches[1])
 			} else if len(definedArgs) != amt {
 				logrus.Warnf("amount of counted args %d, differs from the amount of build args %d\n", len(definedArgs), amt)
@@ -61,14 +75,15 @@ func (i image) ParseBuildArguments() ([]BuildArg, error) {
 			regxp, err := regexp.Compile(strings.Join(append(definedArgs, shell), `=(.*)\s`))
 			if err != nil {
 				logrus.Errorf("unable to compile regex %s\n", err)
+				continue
 			}
-			if matches = regxp.FindStringSubmatch(h.CreatedBy); matches != nil {
+			if matches = regxp.FindStringSubmatch(createdBy); matches != nil {
 				// TODO(i dont love this)
 				buildArgs = append(buildArgs, util.ZipApply(func(name string, value string) BuildArg {
 					return BuildArg{
 						Name:     name,
 						Value:    value,
-						Location: h.CreatedBy,
+						Location: createdBy,
 					}
 				}, definedArgs, matches[1:])...)
 			} else {
@@ -78,9 +93,7 @@ func (i image) ParseBuildArguments() ([]BuildArg, error) {
 	}
 
 	// remove duplicates
-	buildArgs = uniqueBuildArgs(buildArgs)
-
-	return buildArgs, err
+	return uniqueBuildArgs(buildArgs)
 }
 
 func uniqueBuildArgs(buildArgs []BuildArg) (unique []BuildArg) {
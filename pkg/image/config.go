@@ -0,0 +1,95 @@
+package image
+
+import (
+	"github.com/bthuilot/dockerleaks/pkg/image/container"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+)
+
+// configImage implements the parts of the Image interface that only
+// require the image configuration and layers. It is shared by all
+// images that are read without a docker daemon.
+type configImage struct {
+	// name is the name the image was referred to by
+	name string
+	// digest is the digest identifying the image
+	digest string
+	// config is the image configuration
+	config ocispec.Image
+	// layers are the filesystem layers of the image, from base to top
+	layers []Layer
+}
+
+// ParseEnvVars will parse out the environment variables set in the image configuration
+func (c configImage) ParseEnvVars() ([]EnvVar, error) {
+	return parseEnvVars(c.config.Config.Env), nil
+}
+
+// ParseBuildArguments will parse out the build arguments from the
+// history stored in the image configuration
+func (c configImage) ParseBuildArguments() ([]BuildArg, error) {
+	lines := make([]string, 0, len(c.config.History))
+	for _, h := range c.config.History {
+		lines = append(lines, h.CreatedBy)
+	}
+	return parseBuildArguments(lines), nil
+}
+
+// Pull does nothing, since the image is already available locally
+func (c configImage) Pull() error {
+	logrus.Warn("image is not backed by a registry, skipping pull")
+	return nil
+}
+
+func (c configImage) Layers() ([]Layer, error) {
+	return c.layers, nil
+}
+
+func (c configImage) ParseFS() (fs.FS, error) {
+	return NewFS(c.layers)
+}
+
+func (c configImage) CreateContainer(ContainerOpts) (container.Container, error) {
+	return nil, ErrNoDaemon
+}
+
+func (c configImage) DestroyContainer(container.Container) error {
+	return ErrNoDaemon
+}
+
+func (c configImage) Name() string {
+	return c.name
+}
+
+func (c configImage) Digest() (string, error) {
+	return c.digest, nil
+}
+
+func (c configImage) Close() error {
+	return nil
+}
+
+// diffID will return the diff ID for the layer at the given index
+// from the image configuration, or the fallback if it is not present
+func (c configImage) diffID(idx int, fallback string) string {
+	if idx < len(c.config.RootFS.DiffIDs) {
+		return c.config.RootFS.DiffIDs[idx].String()
+	}
+	return fallback
+}
+
+// createdBy will return the history entry that created the layer at the given index.
+// History entries for instructions that do not create a layer (i.e. ENV) are skipped
+func (c configImage) createdBy(idx int) string {
+	for _, h := range c.config.History {
+		if h.EmptyLayer {
+			continue
+		}
+		if idx == 0 {
+			return h.CreatedBy
+		}
+		idx--
+	}
+	return ""
+}
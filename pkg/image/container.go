@@ -10,32 +10,102 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func (i image) CreateContainer() (container.Container, error) {
+// neverStartedEntrypoint is the entrypoint set on containers that are only
+// created and never started, so that images without a command can be created
+const neverStartedEntrypoint = "/dockerleaks-container-not-started"
+
+// ContainerOpts is used to configure a container created from an image
+type ContainerOpts struct {
+	// Start will start the container after it is created, running the
+	// entrypoint of the image inside a sandbox. If false, the container
+	// is only created, which is enough to export its filesystem.
+	Start bool
+	// Memory is the memory limit of the container in bytes
+	Memory int64
+	// CPUs is the amount of CPUs the container may use
+	CPUs float64
+	// AllowNetwork will allow the started container to access the network
+	AllowNetwork bool
+	// WritableRootfs will allow the started container to write to its root
+	// filesystem, which is required to inspect files written at runtime
+	WritableRootfs bool
+}
+
+// DefaultContainerOpts are the default options for creating a container,
+// which only create the container without starting it
+var DefaultContainerOpts = ContainerOpts{
+	Memory: 512 * 1024 * 1024,
+	CPUs:   1,
+}
+
+func (i *image) CreateContainer(opts ContainerOpts) (container.Container, error) {
 	// create container
 	name := fmt.Sprintf("dockerleaks-scan-%s", uuid.Generate())
-	resp, err := i.cli.ContainerCreate(i.ctx, &containerTypes.Config{
-		Image: i.ref.String(),
-	}, nil, nil, nil, name)
+	resp, err := i.cli.ContainerCreate(i.ctx, sandboxConfig(i.ref.String(), opts), sandboxHostConfig(opts), nil, nil, name)
 	if err != nil {
 		logrus.Errorf("failure creating container: %s", err)
 		return nil, errors.New("unable to create container")
 	}
 
-	// start container
-	if err = i.cli.ContainerStart(i.ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		logrus.Errorf("failure starting container: %s", err)
-		return nil, errors.New("unable to start container")
-	}
-
-	return container.New(
+	c, err := container.New(
 		resp.ID,
 		name,
 		i.cli,
 		i.ctx,
 	)
+	if err != nil || !opts.Start {
+		return c, err
+	}
+
+	// start container
+	logrus.Warnf("starting container %s from image %s", name, i.ref.String())
+	if err = c.Start(); err != nil {
+		if rmErr := i.DestroyContainer(c); rmErr != nil {
+			logrus.Errorf("failure removing container: %s", rmErr)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// sandboxConfig will return the configuration of a container created from the image,
+// with networking disabled unless allowed by the options
+func sandboxConfig(image string, opts ContainerOpts) *containerTypes.Config {
+	config := &containerTypes.Config{
+		Image:           image,
+		NetworkDisabled: !opts.AllowNetwork,
+	}
+	if !opts.Start {
+		// the entrypoint is never run, but is required for images without one
+		config.Entrypoint = []string{neverStartedEntrypoint}
+	}
+	return config
+}
+
+// sandboxHostConfig will return the host configuration of a container, which
+// disables privilege escalation, drops all capabilities and limits the resources
+// of the container. Networking and writes to the root filesystem are disabled
+// unless allowed by the options
+func sandboxHostConfig(opts ContainerOpts) *containerTypes.HostConfig {
+	pidsLimit := int64(256)
+	networkMode := containerTypes.NetworkMode("none")
+	if opts.AllowNetwork {
+		networkMode = "bridge"
+	}
+	return &containerTypes.HostConfig{
+		NetworkMode:    networkMode,
+		ReadonlyRootfs: !opts.WritableRootfs,
+		CapDrop:        []string{"ALL"},
+		SecurityOpt:    []string{"no-new-privileges"},
+		Resources: containerTypes.Resources{
+			Memory:    opts.Memory,
+			NanoCPUs:  int64(opts.CPUs * 1e9),
+			PidsLimit: &pidsLimit,
+		},
+	}
 }
 
-func (i image) DestroyContainer(c container.Container) error {
+func (i *image) DestroyContainer(c container.Container) error {
 	if err := c.Stop(); err != nil {
 		logrus.Errorf("failure stopping container: %s", err)
 		return err
@@ -5,6 +5,7 @@ import (
 	"context"
 	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
+	"io"
 )
 
 type Container interface {
@@ -20,6 +21,18 @@ type Container interface {
 
 	// Export will export the container filesystem to a tarball
 	Export() (*tar.Reader, error)
+
+	// WaitReady will block until the running container meets the ready condition
+	WaitReady(ReadyCondition) error
+
+	// Changes will return the paths of the files that were added
+	// or modified in the container since it was created
+	Changes() ([]string, error)
+
+	// CopyFile will open the contents of the regular file at path in the container,
+	// returning the size of the file in bytes alongside the contents.
+	// The caller is responsible for closing the returned reader
+	CopyFile(path string) (io.ReadCloser, int64, error)
 }
 
 type container struct {
@@ -32,7 +45,7 @@ type container struct {
 
 func New(id string, name string, cli *client.Client, ctx context.Context) (Container, error) {
 	logrus.Debugf("creating new container: %s", id)
-	return container{
+	return &container{
 		id:      id,
 		name:    name,
 		cli:     cli,
@@ -41,11 +54,11 @@ func New(id string, name string, cli *client.Client, ctx context.Context) (Conta
 	}, nil
 }
 
-func (c container) ID() string {
+func (c *container) ID() string {
 	return c.id
 }
 
-func (c container) Export() (*tar.Reader, error) {
+func (c *container) Export() (*tar.Reader, error) {
 	// export container filesystem
 	resp, err := c.cli.ContainerExport(c.ctx, c.id)
 	if err != nil {
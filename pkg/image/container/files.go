@@ -0,0 +1,49 @@
+package container
+
+import (
+	"archive/tar"
+	"fmt"
+	containerTypes "github.com/docker/docker/api/types/container"
+	"io"
+)
+
+func (c *container) Changes() ([]string, error) {
+	changes, err := c.cli.ContainerDiff(c.ctx, c.id)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, change := range changes {
+		if change.Kind == containerTypes.ChangeDelete {
+			continue
+		}
+		paths = append(paths, change.Path)
+	}
+	return paths, nil
+}
+
+func (c *container) CopyFile(path string) (io.ReadCloser, int64, error) {
+	stat, err := c.cli.ContainerStatPath(c.ctx, c.id, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !stat.Mode.IsRegular() {
+		return nil, 0, fmt.Errorf("'%s' is not a regular file", path)
+	}
+
+	rc, _, err := c.cli.CopyFromContainer(c.ctx, c.id, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	// the file is returned as the only entry of a tarball
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err != nil {
+		_ = rc.Close()
+		return nil, 0, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{tr, rc}, hdr.Size, nil
+}
@@ -0,0 +1,136 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+	"io"
+	"regexp"
+	"time"
+)
+
+// healthPollInterval is how often the health of a container is checked
+const healthPollInterval = time.Second
+
+// ReadyCondition is the condition a started container must meet
+// to be considered ready to be inspected
+type ReadyCondition struct {
+	// Delay is the time to wait after the container has started
+	Delay time.Duration
+	// LogPattern will wait until a line of the container logs matches the pattern
+	LogPattern *regexp.Regexp
+	// Healthy will wait until the health check of the container
+	// reports healthy, if the image defines one
+	Healthy bool
+	// Timeout is the maximum time to wait for the container to be ready
+	Timeout time.Duration
+}
+
+func (c *container) WaitReady(cond ReadyCondition) error {
+	if !c.running {
+		return errors.New("container not running")
+	}
+	ctx := c.ctx
+	if cond.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cond.Timeout)
+		defer cancel()
+	}
+
+	if cond.LogPattern != nil {
+		logrus.Debugf("waiting for container logs to match '%s'", cond.LogPattern)
+		if err := c.waitForLog(ctx, cond.LogPattern); err != nil {
+			return err
+		}
+	}
+
+	if cond.Healthy {
+		logrus.Debugf("waiting for container to report healthy")
+		if err := c.waitForHealthy(ctx); err != nil {
+			return err
+		}
+	}
+
+	if cond.Delay > 0 {
+		logrus.Debugf("waiting %s for container to be ready", cond.Delay)
+		select {
+		case <-time.After(cond.Delay):
+		case <-ctx.Done():
+			return errors.New("timed out waiting for container to be ready")
+		}
+	}
+	return c.checkRunning()
+}
+
+// waitForLog will block until a line of the container logs matches the pattern
+func (c *container) waitForLog(ctx context.Context, pattern *regexp.Regexp) error {
+	logs, err := c.cli.ContainerLogs(ctx, c.id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	// logs are multiplexed since no TTY is attached
+	pr, pw := io.Pipe()
+	// closing the reader stops the copy once a line matches
+	defer pr.Close()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, logs)
+		_ = pw.CloseWithError(err)
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	for scanner.Scan() {
+		if pattern.MatchString(scanner.Text()) {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return errors.New("timed out waiting for container logs to match")
+	}
+	return errors.New("container exited before logs matched")
+}
+
+// waitForHealthy will block until the health check of the container reports healthy
+func (c *container) waitForHealthy(ctx context.Context) error {
+	for {
+		inspect, err := c.cli.ContainerInspect(ctx, c.id)
+		if err != nil {
+			return err
+		}
+		if inspect.State == nil || inspect.State.Health == nil {
+			logrus.Warn("image does not define a health check, skipping")
+			return nil
+		}
+		switch inspect.State.Health.Status {
+		case types.Healthy:
+			return nil
+		case types.Unhealthy:
+			return errors.New("container health check reported unhealthy")
+		}
+		select {
+		case <-time.After(healthPollInterval):
+		case <-ctx.Done():
+			return errors.New("timed out waiting for container to be healthy")
+		}
+	}
+}
+
+// checkRunning will return an error if the container is no longer running
+func (c *container) checkRunning() error {
+	inspect, err := c.cli.ContainerInspect(c.ctx, c.id)
+	if err != nil {
+		return err
+	}
+	if inspect.State == nil || !inspect.State.Running {
+		return errors.New("container exited before it was ready")
+	}
+	return nil
+}
@@ -1,14 +1,15 @@
 package container
 
 import (
+	"bytes"
 	"errors"
 	"github.com/docker/docker/api/types"
 	containerTypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/sirupsen/logrus"
-	"io"
 )
 
-func (c container) Stop() error {
+func (c *container) Stop() error {
 	if !c.running {
 		return nil
 	}
@@ -17,28 +18,32 @@ func (c container) Stop() error {
 		logrus.Errorf("failure stopping container: %s", err)
 		return errors.New("unable to stop container")
 	}
+	c.running = false
 	return nil
 }
 
-func (c container) Start() error {
+func (c *container) Start() error {
 	if c.running {
 		return errors.New("container already running")
 	}
 
-	if err := c.cli.ContainerStop(c.ctx, c.id, containerTypes.StopOptions{}); err != nil {
-		logrus.Errorf("failure stopping container: %s", err)
-		return errors.New("unable to stop container")
+	if err := c.cli.ContainerStart(c.ctx, c.id, types.ContainerStartOptions{}); err != nil {
+		logrus.Errorf("failure starting container: %s", err)
+		return errors.New("unable to start container")
 	}
+	c.running = true
 	return nil
 }
 
-func (c container) RunCommand(cmd string) (string, error) {
+func (c *container) RunCommand(cmd string) (string, error) {
 	if !c.running {
 		return "", errors.New("container not running")
 	}
 	// exec command
 	exec, err := c.cli.ContainerExecCreate(c.ctx, c.id, types.ExecConfig{
-		Cmd: []string{"sh", "-c", cmd},
+		Cmd:          []string{"sh", "-c", cmd},
+		AttachStdout: true,
+		AttachStderr: true,
 	})
 	if err != nil {
 		return "", err
@@ -49,7 +54,13 @@ func (c container) RunCommand(cmd string) (string, error) {
 		return "", err
 	}
 	defer resp.Close()
-	// read output
-	output, err := io.ReadAll(resp.Reader)
-	return string(output), err
+	// read output, which is multiplexed since no TTY is attached
+	var stdout, stderr bytes.Buffer
+	if _, err = stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		return "", err
+	}
+	if stderr.Len() > 0 {
+		logrus.Debugf("command '%s' wrote to stderr: %s", cmd, stderr.String())
+	}
+	return stdout.String(), nil
 }
@@ -0,0 +1,49 @@
+package image
+
+import "testing"
+
+func TestSandboxHostConfig(t *testing.T) {
+	hostConfig := sandboxHostConfig(DefaultContainerOpts)
+	if hostConfig.NetworkMode != "none" {
+		t.Errorf("expected no network, got '%s'", hostConfig.NetworkMode)
+	}
+	if !hostConfig.ReadonlyRootfs {
+		t.Errorf("expected a read-only root filesystem")
+	}
+	if len(hostConfig.CapDrop) != 1 || hostConfig.CapDrop[0] != "ALL" {
+		t.Errorf("expected all capabilities to be dropped, got %v", hostConfig.CapDrop)
+	}
+	if len(hostConfig.SecurityOpt) != 1 || hostConfig.SecurityOpt[0] != "no-new-privileges" {
+		t.Errorf("expected privilege escalation to be disabled, got %v", hostConfig.SecurityOpt)
+	}
+	resources := hostConfig.Resources
+	if resources.Memory != 512*1024*1024 || resources.NanoCPUs != 1e9 {
+		t.Errorf("expected 512MiB of memory and 1 CPU, got %d bytes and %d nano CPUs", resources.Memory, resources.NanoCPUs)
+	}
+	if resources.PidsLimit == nil || *resources.PidsLimit != 256 {
+		t.Errorf("expected a limit of 256 processes, got %v", resources.PidsLimit)
+	}
+
+	opts := DefaultContainerOpts
+	opts.AllowNetwork, opts.WritableRootfs = true, true
+	if hostConfig = sandboxHostConfig(opts); hostConfig.NetworkMode != "bridge" || hostConfig.ReadonlyRootfs {
+		t.Errorf("expected network and a writable root filesystem, got '%s' and read-only %t", hostConfig.NetworkMode, hostConfig.ReadonlyRootfs)
+	}
+}
+
+func TestSandboxConfig(t *testing.T) {
+	// containers are only created, never started, by default
+	config := sandboxConfig("alpine:latest", DefaultContainerOpts)
+	if config.Image != "alpine:latest" || !config.NetworkDisabled {
+		t.Errorf("expected networking to be disabled for alpine:latest, got %t for %s", config.NetworkDisabled, config.Image)
+	}
+	if len(config.Entrypoint) != 1 || config.Entrypoint[0] != neverStartedEntrypoint {
+		t.Errorf("expected the entrypoint of a container that is never started, got %v", config.Entrypoint)
+	}
+
+	opts := DefaultContainerOpts
+	opts.Start = true
+	if config = sandboxConfig("alpine:latest", opts); len(config.Entrypoint) != 0 {
+		t.Errorf("expected the entrypoint of the image for a started container, got %v", config.Entrypoint)
+	}
+}
@@ -9,13 +9,19 @@ import (
 // ParseEnvVars will parse out environment variables by
 // inspecting the image and pull out each environment
 // and split on the first '=' into the name and value
-func (i image) ParseEnvVars() ([]EnvVar, error) {
+func (i *image) ParseEnvVars() ([]EnvVar, error) {
 	imageInspect, _, err := i.cli.ImageInspectWithRaw(i.ctx, i.ref.String())
 	if err != nil {
 		return nil, err
 	}
+	return parseEnvVars(imageInspect.Config.Env), nil
+}
+
+// parseEnvVars will split each environment variable from an image
+// configuration on the first '=' into the name and value
+func parseEnvVars(env []string) []EnvVar {
 	var vars []EnvVar
-	for _, env := range imageInspect.Config.Env {
+	for _, env := range env {
 		splitEnv := strings.SplitN(env, "=", 2)
 		if len(splitEnv) != 2 {
 			logrus.Warnf("skipping invalid env %s", env)
@@ -27,7 +33,7 @@ func (i image) ParseEnvVars() ([]EnvVar, error) {
 			Location: env,
 		})
 	}
-	return uniqueEnvVars(vars), nil
+	return uniqueEnvVars(vars)
 }
 
 func uniqueEnvVars(envVars []EnvVar) (unique []EnvVar) {
@@ -0,0 +1,300 @@
+package image
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// maxSymlinks is the maximum amount of symbolic links
+// followed when resolving a path, to prevent loops
+const maxSymlinks = 40
+
+// layerFS is a read only view of the merged filesystem of the layers
+// of an image, implementing fs.FS, fs.ReadDirFS and fs.StatFS.
+//
+// The contents of a file are read from the layer that added it when the
+// file is opened, so each call to Open reads the layer up to the file.
+// Searching every file in the image is better done by reading each layer
+// in order (see [Merger]).
+type layerFS struct {
+	// layers are the layers of the image, from base to top
+	layers []Layer
+	// files are the provenance of each path in the merged filesystem,
+	// including any directories only implied by the paths of files
+	files map[string]Provenance
+	// children are the sorted names of the entries of each directory
+	children map[string][]string
+}
+
+// NewFS will construct a new fs.FS of the merged filesystem of the layers
+func NewFS(layers []Layer) (fs.FS, error) {
+	merger, err := Merge(layers)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := &layerFS{
+		layers:   layers,
+		files:    make(map[string]Provenance),
+		children: make(map[string][]string),
+	}
+	fsys.addDir(".")
+	for _, p := range merger.Files() {
+		if p.Path == "" {
+			continue
+		}
+		fsys.addParents(p.Path)
+		if p.Header.Typeflag == tar.TypeDir {
+			fsys.addDir(p.Path)
+		}
+		fsys.files[p.Path] = p
+	}
+	for _, names := range fsys.children {
+		sort.Strings(names)
+	}
+	return fsys, nil
+}
+
+// addDir will add an empty directory with the given path
+func (l *layerFS) addDir(name string) {
+	if _, ok := l.children[name]; !ok {
+		l.children[name] = []string{}
+	}
+}
+
+// addParents will add the path to the entries of its parent directory,
+// creating any parent directories that are not present in the layers
+func (l *layerFS) addParents(name string) {
+	for name != "." {
+		parent := path.Dir(name)
+		_, exists := l.children[parent]
+		l.children[parent] = append(l.children[parent], path.Base(name))
+		if _, ok := l.files[parent]; !ok && parent != "." {
+			l.files[parent] = Provenance{
+				Path:      parent,
+				Header:    &tar.Header{Name: parent, Typeflag: tar.TypeDir, Mode: 0o755},
+				RemovedIn: -1,
+			}
+		}
+		if exists {
+			return
+		}
+		name = parent
+	}
+}
+
+// Open will open the named file, following any symbolic links
+func (l *layerFS) Open(name string) (fs.File, error) {
+	p, err := l.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := l.fileInfo(name, p)
+	if info.IsDir() {
+		return &dirFile{info: info, entries: l.entries(p.Path)}, nil
+	}
+
+	rc, err := l.openContents(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &layerFile{info: info, ReadCloser: rc}, nil
+}
+
+// Stat will return the file info of the named file, following any symbolic links
+func (l *layerFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := l.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return l.fileInfo(name, p), nil
+}
+
+// ReadDir will read the named directory, returning its entries sorted by name
+func (l *layerFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := l.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !l.fileInfo(name, p).IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return l.entries(p.Path), nil
+}
+
+// resolve will return the provenance of the named file, following symbolic links
+func (l *layerFS) resolve(op, name string) (Provenance, error) {
+	if !fs.ValidPath(name) {
+		return Provenance{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return l.root(), nil
+	}
+	for i := 0; i < maxSymlinks; i++ {
+		p, err := l.lookup(name)
+		if err != nil {
+			return Provenance{}, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		if p.Header.Typeflag != tar.TypeSymlink {
+			return p, nil
+		}
+		name = l.linkTarget(p.Path, p.Header.Linkname)
+		if name == "." {
+			return l.root(), nil
+		}
+	}
+	return Provenance{}, &fs.PathError{Op: op, Path: name, Err: errors.New("too many symbolic links")}
+}
+
+// lookup will return the provenance of the path, resolving
+// any symbolic links in the parent directories of the path
+func (l *layerFS) lookup(name string) (Provenance, error) {
+	if p, ok := l.files[name]; ok {
+		return p, nil
+	}
+	dir, base := path.Split(name)
+	if dir == "" {
+		return Provenance{}, fs.ErrNotExist
+	}
+	parent, err := l.lookup(path.Clean(dir))
+	if err != nil || parent.Header.Typeflag != tar.TypeSymlink {
+		return Provenance{}, fs.ErrNotExist
+	}
+	target := path.Join(l.linkTarget(parent.Path, parent.Header.Linkname), base)
+	if target == name {
+		return Provenance{}, fs.ErrNotExist
+	}
+	return l.lookup(target)
+}
+
+// linkTarget will return the path a link located at name points to,
+// relative to the root of the filesystem
+func (l *layerFS) linkTarget(name, link string) string {
+	if !path.IsAbs(link) {
+		link = path.Join(path.Dir(name), link)
+	}
+	if target := CleanPath(link); target != "" {
+		return target
+	}
+	return "."
+}
+
+// root is the provenance of the root directory of the filesystem
+func (l *layerFS) root() Provenance {
+	return Provenance{
+		Path:      ".",
+		Header:    &tar.Header{Name: ".", Typeflag: tar.TypeDir, Mode: 0o755},
+		RemovedIn: -1,
+	}
+}
+
+// fileInfo will return the file info of the path, named by the name it was opened with
+func (l *layerFS) fileInfo(name string, p Provenance) fs.FileInfo {
+	return fileInfo{FileInfo: p.Header.FileInfo(), name: path.Base(name)}
+}
+
+// entries will return the entries of the directory
+func (l *layerFS) entries(dir string) []fs.DirEntry {
+	names := l.children[dir]
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, n := range names {
+		child := path.Join(dir, n)
+		entries = append(entries, fs.FileInfoToDirEntry(l.fileInfo(child, l.files[child])))
+	}
+	return entries
+}
+
+// openContents will open the contents of the file from the layer that added it
+func (l *layerFS) openContents(p Provenance) (io.ReadCloser, error) {
+	// hard links store their contents in the linked file
+	if p.Header.Typeflag == tar.TypeLink {
+		target, ok := l.files[CleanPath(p.Header.Linkname)]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		p = target
+	}
+	if p.AddedIn >= len(l.layers) {
+		return nil, fs.ErrNotExist
+	}
+
+	rc, err := l.layers[p.AddedIn].Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			_ = rc.Close()
+			return nil, fs.ErrNotExist
+		}
+		if err != nil {
+			_ = rc.Close()
+			return nil, err
+		}
+		if CleanPath(hdr.Name) == p.Path {
+			return readCloser{Reader: tr, Closer: rc}, nil
+		}
+	}
+}
+
+// fileInfo is the fs.FileInfo of a file in a layer
+type fileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (f fileInfo) Name() string {
+	return f.name
+}
+
+// layerFile is a regular file opened from a layer
+type layerFile struct {
+	io.ReadCloser
+	info fs.FileInfo
+}
+
+func (f *layerFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// dirFile is a directory opened from the filesystem
+type dirFile struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
@@ -0,0 +1,78 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// testEntry is an entry of a layer constructed by testLayer
+type testEntry struct {
+	hdr     *tar.Header
+	content string
+}
+
+// testLayer will construct an in memory Layer with the given entries, in order
+func testLayer(t *testing.T, entries []testEntry) Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.content))
+		if err := tw.WriteHeader(e.hdr); err != nil {
+			t.Fatalf("unexpected error writing header: %s", err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("unexpected error writing content: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected error closing layer: %s", err)
+	}
+	return layer{
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+		},
+	}
+}
+
+func TestFS(t *testing.T) {
+	reg := func(name string) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644}
+	}
+	layers := []Layer{
+		testLayer(t, []testEntry{
+			{&tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755}, ""},
+			{reg("etc/passwd"), "root:x:0:0"},
+			{reg("app/secret.key"), "key"},
+			{reg("app/main.py"), "print('v1')"},
+		}),
+		testLayer(t, []testEntry{
+			{reg("app/.wh.secret.key"), ""},
+			{reg("app/main.py"), "print('v2')"},
+			{&tar.Header{Name: "usr/lib/link", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd"}, ""},
+		}),
+	}
+
+	fsys, err := NewFS(layers)
+	if err != nil {
+		t.Fatalf("unexpected error creating filesystem: %s", err)
+	}
+
+	if err = fstest.TestFS(fsys, "etc/passwd", "app/main.py", "usr/lib/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = fs.Stat(fsys, "app/secret.key"); err == nil {
+		t.Errorf("expected deleted file to not exist")
+	}
+	for name, expected := range map[string]string{"app/main.py": "print('v2')", "usr/lib/link": "root:x:0:0"} {
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil || string(content) != expected {
+			t.Errorf("expected %s to contain '%s', got '%s' (%v)", name, expected, content, err)
+		}
+	}
+}
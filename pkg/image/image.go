@@ -10,6 +10,9 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 	"io"
+	"io/fs"
+	"os"
+	"strings"
 )
 
 // Image represents a built docker image
@@ -24,15 +27,38 @@ type Image interface {
 	ParseBuildArguments() ([]BuildArg, error)
 	// Pull will pull down an image from remote
 	Pull() error
-	//ParseFS() (fs.FS, error)
+	// ParseFS will return a read only view of the final filesystem
+	// of the image, constructed from the layers of the image
+	ParseFS() (fs.FS, error)
 
-	// CreateContainer will start a container from this image
-	CreateContainer() (container.Container, error)
+	// Layers will return the filesystem layers of the image,
+	// ordered from the base layer to the top most layer
+	Layers() ([]Layer, error)
+
+	// CreateContainer will create a container from this image, only starting
+	// it if requested by the options. Images that are not backed by a docker
+	// daemon will return ErrNoDaemon
+	CreateContainer(ContainerOpts) (container.Container, error)
 
 	// DestroyContainer will remove a container from the docker daemon
 	DestroyContainer(container.Container) error
+
+	// Name will return the name the image was referred to by,
+	// such as its reference, tag or the path it was read from
+	Name() string
+
+	// Digest will return the digest identifying the image, which is the digest
+	// of its manifest if known, otherwise the digest of its configuration
+	Digest() (string, error)
+
+	// Close will release any resources held by the image
+	Close() error
 }
 
+// ErrNoDaemon is returned when an operation requiring a docker daemon
+// is performed on an image that was not loaded from one
+var ErrNoDaemon = errors.New("operation requires a docker daemon")
+
 // image is the concrete implementation of the Image interface
 type image struct {
 	// ref is the parsed image reference supplied by the user
@@ -41,10 +67,27 @@ type image struct {
 	cli *client.Client
 	// ctx is the global context for all client interactions
 	ctx context.Context
+	// saved is the image exported from the daemon via `docker save`,
+	// populated the first time the layers are requested
+	saved *archiveImage
 }
 
-// NewImage connects to the docker daemon and constructs a new Image from its reference
-func NewImage(name string) (Image, error) {
+// Daemon is a connection to a docker daemon, shared by the images read from it
+type Daemon interface {
+	// Image will construct a new Image from its reference, read from the daemon
+	Image(name string) (Image, error)
+}
+
+// daemon is the concrete implementation of the Daemon interface
+type daemon struct {
+	// cli is the moby client.Client for interacting with docker
+	cli *client.Client
+	// ctx is the global context for all client interactions
+	ctx context.Context
+}
+
+// NewDaemon connects to the docker daemon configured by the environment
+func NewDaemon() (Daemon, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		logrus.Errorf("failure constructing new client: %s", err)
@@ -52,26 +95,37 @@ func NewImage(name string) (Image, error) {
 	}
 
 	ctx := context.Background()
+	if _, err = cli.Ping(ctx); err != nil {
+		return nil, errors.New("docker daemon is not running")
+	}
+	return daemon{cli: cli, ctx: ctx}, nil
+}
 
+// NewImage connects to the docker daemon and constructs a new Image from its reference
+func NewImage(name string) (Image, error) {
+	d, err := NewDaemon()
+	if err != nil {
+		return nil, err
+	}
+	return d.Image(name)
+}
+
+func (d daemon) Image(name string) (Image, error) {
 	ref, err := reference.ParseAnyReference(name)
 	if err != nil {
 		logrus.Errorf("failure parsing docker name: %s", err)
 		return nil, errors.New("invalid docker name")
 	}
 
-	if _, err = cli.Ping(ctx); err != nil {
-		return nil, errors.New("docker daemon is not running")
-	}
-
-	return image{
+	return &image{
 		ref: ref,
-		cli: cli,
-		ctx: ctx,
-	}, err
+		cli: d.cli,
+		ctx: d.ctx,
+	}, nil
 }
 
 // Pull will pull down the image from remote.
-func (i image) Pull() error {
+func (i *image) Pull() error {
 	reader, err := i.cli.ImagePull(i.ctx, i.ref.String(), types.ImagePullOptions{})
 	if err != nil {
 		logrus.Errorf("failure pulling docker image '%s': %s", i.ref.String(), err)
@@ -95,7 +149,7 @@ func (i image) Pull() error {
 	return nil
 }
 
-func (i image) Delete() error {
+func (i *image) Delete() error {
 	_, err := i.cli.ImageRemove(i.ctx, i.ref.String(), types.ImageRemoveOptions{})
 	if err != nil {
 		logrus.Errorf("failure removing docker image '%s': %s", i.ref.String(), err)
@@ -103,3 +157,81 @@ func (i image) Delete() error {
 	}
 	return nil
 }
+
+// Layers will export the image from the docker daemon as
+// a `docker save` archive and return the layers of the archive
+func (i *image) Layers() ([]Layer, error) {
+	if i.saved == nil {
+		saved, err := i.save()
+		if err != nil {
+			return nil, err
+		}
+		i.saved = saved
+	}
+	return i.saved.Layers()
+}
+
+// ParseFS will return the merged filesystem of the layers of the image
+func (i *image) ParseFS() (fs.FS, error) {
+	layers, err := i.Layers()
+	if err != nil {
+		return nil, err
+	}
+	return NewFS(layers)
+}
+
+// save will write the image to a temporary `docker save` archive
+func (i *image) save() (*archiveImage, error) {
+	reader, err := i.cli.ImageSave(i.ctx, []string{i.ref.String()})
+	if err != nil {
+		logrus.Errorf("failure saving docker image '%s': %s", i.ref.String(), err)
+		return nil, errors.New("unable to save docker image")
+	}
+	defer reader.Close()
+
+	f, err := os.CreateTemp("", "dockerleaks-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	logrus.Debugf("saving image %s to %s", i.ref.String(), f.Name())
+	if _, err = io.Copy(f, reader); err != nil {
+		_ = os.Remove(f.Name())
+		logrus.Errorf("failure writing docker image archive: %s", err)
+		return nil, errors.New("unable to save docker image")
+	}
+	archive, err := newArchiveImage(f.Name(), "")
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (i *image) Name() string {
+	return i.ref.String()
+}
+
+// Digest will return the repository digest of the image if it was pulled from
+// a registry, otherwise the ID of the image
+func (i *image) Digest() (string, error) {
+	inspect, _, err := i.cli.ImageInspectWithRaw(i.ctx, i.ref.String())
+	if err != nil {
+		logrus.Errorf("failure inspecting docker image '%s': %s", i.ref.String(), err)
+		return "", errors.New("unable to inspect docker image")
+	}
+	for _, repoDigest := range inspect.RepoDigests {
+		if _, d, ok := strings.Cut(repoDigest, "@"); ok {
+			return d, nil
+		}
+	}
+	return inspect.ID, nil
+}
+
+// Close will remove the saved image archive, if one was created
+func (i *image) Close() error {
+	if i.saved == nil {
+		return nil
+	}
+	return os.Remove(i.saved.path)
+}
@@ -0,0 +1,75 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Layer represents a single filesystem layer of an image
+type Layer interface {
+	// Digest is the digest of the uncompressed layer contents
+	// (also known as the diff ID of the layer)
+	Digest() string
+	// CreatedBy is the command from the image history that created the layer
+	CreatedBy() string
+	// Uncompressed will open the uncompressed tar stream of the layer.
+	// The caller is responsible for closing the returned reader
+	Uncompressed() (io.ReadCloser, error)
+}
+
+// layer is the concrete implementation of the Layer interface
+type layer struct {
+	// digest is the diff ID of the layer
+	digest string
+	// createdBy is the history entry that created the layer
+	createdBy string
+	// open will open the (possibly compressed) contents of the layer
+	open func() (io.ReadCloser, error)
+}
+
+func (l layer) Digest() string {
+	return l.digest
+}
+
+func (l layer) CreatedBy() string {
+	return l.createdBy
+}
+
+func (l layer) Uncompressed() (io.ReadCloser, error) {
+	rc, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+	return decompress(rc)
+}
+
+// gzipMagic is the header of a gzip compressed stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress will wrap the reader in a gzip reader if the stream
+// is gzip compressed, otherwise the stream is returned as is
+func decompress(rc io.ReadCloser) (io.ReadCloser, error) {
+	buf := bufio.NewReader(rc)
+	header, err := buf.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		_ = rc.Close()
+		return nil, err
+	}
+	if !bytes.Equal(header, gzipMagic) {
+		return readCloser{Reader: buf, Closer: rc}, nil
+	}
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+	return readCloser{Reader: gz, Closer: rc}, nil
+}
+
+// readCloser combines a reader with the closer of the underlying stream
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,267 @@
+package image
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// whiteoutPrefix is the prefix of a file in a layer that marks
+	// the file of the same name from a lower layer as deleted
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout is the file in a layer that marks all the
+	// contents of its directory from lower layers as deleted
+	opaqueWhiteout = ".wh..wh..opq"
+)
+
+// Removal is how a version of a path was removed from the image filesystem
+type Removal string
+
+const (
+	// NotRemoved paths are present in the final image filesystem
+	NotRemoved Removal = ""
+	// Overwritten paths are replaced by the same path in a later layer
+	Overwritten Removal = "overwritten"
+	// WhitedOut paths are deleted by a whiteout in a later layer
+	WhitedOut Removal = "whiteout"
+)
+
+// Provenance records the layer that added a version of a path to the
+// image filesystem, and the layer that removed it (if any)
+type Provenance struct {
+	// Path is the normalized path of the file, without a leading '/'
+	Path string
+	// Header is the tar header of the file in the layer that added it
+	Header *tar.Header
+	// AddedIn is the index of the layer that added the path
+	AddedIn int
+	// AddedBy is the digest of the layer that added the path
+	AddedBy string
+	// RemovedIn is the index of the layer that removed the path, or -1 if it was not removed
+	RemovedIn int
+	// RemovedBy is the digest of the layer that removed the path
+	RemovedBy string
+	// Removal is how the path was removed
+	Removal Removal
+}
+
+// Visible will return true if the path is present in the final image filesystem
+func (p Provenance) Visible() bool {
+	return p.Removal == NotRemoved
+}
+
+// Merger applies the entries of each layer of an image in order, following
+// the [OCI whiteout semantics], to reconstruct the final image filesystem
+// and the provenance of each version of each path.
+//
+// [OCI whiteout semantics]: https://github.com/opencontainers/image-spec/blob/main/layer.md#whiteouts
+type Merger struct {
+	// layers are the layers of the image, from base to top
+	layers []Layer
+	// versions are all versions of each path, keyed by path and layer index
+	versions map[version]*Provenance
+	// visible are the versions of each path present in the merged filesystem
+	visible map[string]*Provenance
+	// children are the paths ever added directly below each directory, including directories
+	// only implied by the paths below them, so removing a tree does not scan every path
+	children map[string]map[string]struct{}
+}
+
+// version is a path as added by a specific layer
+type version struct {
+	path  string
+	layer int
+}
+
+// NewMerger will construct a new Merger for the layers of an image
+func NewMerger(layers []Layer) *Merger {
+	return &Merger{
+		layers:   layers,
+		versions: make(map[version]*Provenance),
+		visible:  make(map[string]*Provenance),
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+// Merge will read the headers of each layer and return the merged result
+func Merge(layers []Layer) (*Merger, error) {
+	m := NewMerger(layers)
+	for idx, l := range layers {
+		if err := m.ApplyLayer(idx, l); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ApplyLayer will read each header of the layer at the given index and apply it
+func (m *Merger) ApplyLayer(idx int, l Layer) error {
+	rc, err := l.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m.Apply(idx, hdr)
+	}
+}
+
+// Apply will apply a single entry of the layer at the given index to the
+// merged filesystem. Layers must be applied in order, and entries of a layer
+// must all be applied before the next layer. The normalized path of the entry
+// is returned, along with whether the entry is a whiteout marker rather than a file.
+func (m *Merger) Apply(idx int, hdr *tar.Header) (name string, whiteout bool) {
+	name = CleanPath(hdr.Name)
+	dir, base := path.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+
+	switch {
+	case base == opaqueWhiteout:
+		m.remove(idx, dir, true, WhitedOut)
+		return dir, true
+	case strings.HasPrefix(base, whiteoutPrefix):
+		target := path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+		m.remove(idx, target, false, WhitedOut)
+		m.remove(idx, target, true, WhitedOut)
+		return target, true
+	}
+
+	// a non directory replaces the entire tree of a directory below it
+	m.remove(idx, name, false, Overwritten)
+	if hdr.Typeflag != tar.TypeDir {
+		m.remove(idx, name, true, Overwritten)
+	}
+
+	p := &Provenance{
+		Path:      name,
+		Header:    hdr,
+		AddedIn:   idx,
+		AddedBy:   m.digest(idx),
+		RemovedIn: -1,
+	}
+	m.versions[version{path: name, layer: idx}] = p
+	m.visible[name] = p
+	m.index(name)
+	return name, false
+}
+
+// remove will mark the path (or its children, if children is true) from lower
+// layers as removed by the layer at the given index. Entries added by the
+// same layer are not removed, since whiteouts only apply to lower layers.
+func (m *Merger) remove(idx int, name string, children bool, removal Removal) {
+	removeVersion := func(p *Provenance) {
+		if p.AddedIn >= idx {
+			return
+		}
+		p.RemovedIn, p.RemovedBy, p.Removal = idx, m.digest(idx), removal
+		delete(m.visible, p.Path)
+	}
+
+	if !children {
+		if p, ok := m.visible[name]; ok {
+			removeVersion(p)
+		}
+		return
+	}
+
+	// only paths that were below the path as a directory are searched
+	pending := []string{name}
+	for len(pending) > 0 {
+		dir := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for child := range m.children[dir] {
+			if p, ok := m.visible[child]; ok {
+				removeVersion(p)
+			}
+			pending = append(pending, child)
+		}
+	}
+}
+
+// index will record the path below its parent directory,
+// and each ancestor of the path below its own parent
+func (m *Merger) index(name string) {
+	for name != "" {
+		parent := path.Dir(name)
+		if parent == "." {
+			parent = ""
+		}
+		siblings, ok := m.children[parent]
+		if !ok {
+			siblings = make(map[string]struct{})
+			m.children[parent] = siblings
+		}
+		if _, ok = siblings[name]; ok {
+			// the ancestors are indexed by the first path added below them
+			return
+		}
+		siblings[name] = struct{}{}
+		name = parent
+	}
+}
+
+// Provenance will return the provenance of the version of
+// the path that was added by the layer at the given index
+func (m *Merger) Provenance(name string, layer int) (Provenance, bool) {
+	p, ok := m.versions[version{path: CleanPath(name), layer: layer}]
+	if !ok {
+		return Provenance{}, false
+	}
+	return *p, true
+}
+
+// Files will return the provenance of each path
+// in the merged filesystem, sorted by path
+func (m *Merger) Files() []Provenance {
+	files := make([]Provenance, 0, len(m.visible))
+	for _, p := range m.visible {
+		files = append(files, *p)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// Removed will return the provenance of each version of a path that
+// was removed from the merged filesystem, sorted by path and layer
+func (m *Merger) Removed() []Provenance {
+	var removed []Provenance
+	for _, p := range m.versions {
+		if !p.Visible() {
+			removed = append(removed, *p)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		if removed[i].Path == removed[j].Path {
+			return removed[i].AddedIn < removed[j].AddedIn
+		}
+		return removed[i].Path < removed[j].Path
+	})
+	return removed
+}
+
+// digest will return the digest of the layer at the given index
+func (m *Merger) digest(idx int) string {
+	if idx < len(m.layers) {
+		return m.layers[idx].Digest()
+	}
+	return ""
+}
+
+// CleanPath will normalize the name of a file in a layer
+// to a relative path without a leading './' or '/'
+func CleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,111 @@
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"testing"
+)
+
+func TestMerger(t *testing.T) {
+	file := func(name string) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: tar.TypeReg}
+	}
+	dir := func(name string) *tar.Header {
+		return &tar.Header{Name: name, Typeflag: tar.TypeDir}
+	}
+
+	layers := [][]*tar.Header{
+		{dir("app/"), file("app/key.pem"), file("app/config.yml"), dir("cache/"), file("cache/a"), file("cache/b")},
+		{file("./app/.wh.key.pem"), file("app/config.yml"), dir("cache/"), file("cache/.wh..wh..opq"), file("cache/c")},
+		{file("app/.wh.app.log"), dir("app/new/"), file("app/new/.wh..wh..opq")},
+	}
+	merger := NewMerger(nil)
+	for idx, headers := range layers {
+		for _, hdr := range headers {
+			merger.Apply(idx, hdr)
+		}
+	}
+
+	var testCases = []struct {
+		path    string
+		layer   int
+		removal Removal
+		removed int
+	}{
+		{"app", 0, NotRemoved, -1},
+		{"app/key.pem", 0, WhitedOut, 1},
+		{"app/config.yml", 0, Overwritten, 1},
+		{"app/config.yml", 1, NotRemoved, -1},
+		{"cache", 0, Overwritten, 1},
+		{"cache/a", 0, WhitedOut, 1},
+		{"cache/b", 0, WhitedOut, 1},
+		// entries in the same layer as an opaque whiteout are not removed
+		{"cache/c", 1, NotRemoved, -1},
+		{"app/new", 2, NotRemoved, -1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			p, ok := merger.Provenance(tc.path, tc.layer)
+			if !ok {
+				t.Fatalf("expected provenance for %s in layer %d", tc.path, tc.layer)
+			}
+			if p.Removal != tc.removal || p.RemovedIn != tc.removed {
+				t.Errorf("expected removal '%s' in layer %d, got '%s' in layer %d", tc.removal, tc.removed, p.Removal, p.RemovedIn)
+			}
+		})
+	}
+
+	var visible []string
+	for _, f := range merger.Files() {
+		visible = append(visible, f.Path)
+	}
+	expected := []string{"app", "app/config.yml", "app/new", "cache", "cache/c"}
+	if len(visible) != len(expected) {
+		t.Fatalf("expected visible files %v, got %v", expected, visible)
+	}
+	for i := range expected {
+		if visible[i] != expected[i] {
+			t.Errorf("expected visible files %v, got %v", expected, visible)
+		}
+	}
+}
+
+func TestMergerImpliedDirectories(t *testing.T) {
+	merger := NewMerger(nil)
+	// the directories of the files have no entries of their own
+	merger.Apply(0, &tar.Header{Name: "usr/lib/a/libx.so", Typeflag: tar.TypeReg})
+	merger.Apply(0, &tar.Header{Name: "usr/lib/b/liby.so", Typeflag: tar.TypeReg})
+	merger.Apply(0, &tar.Header{Name: "usr/bin/sh", Typeflag: tar.TypeReg})
+	merger.Apply(1, &tar.Header{Name: "usr/lib", Typeflag: tar.TypeSymlink})
+
+	for _, name := range []string{"usr/lib/a/libx.so", "usr/lib/b/liby.so"} {
+		if p, _ := merger.Provenance(name, 0); p.Removal != Overwritten {
+			t.Errorf("expected %s to be overwritten, got '%s'", name, p.Removal)
+		}
+	}
+	if p, _ := merger.Provenance("usr/bin/sh", 0); !p.Visible() {
+		t.Errorf("expected usr/bin/sh to be visible, got '%s'", p.Removal)
+	}
+}
+
+// BenchmarkMerger merges two layers of many files, the second overwriting every file of the first
+func BenchmarkMerger(b *testing.B) {
+	const files = 100000
+	headers := make([]*tar.Header, 0, files)
+	for i := 0; i < files; i++ {
+		headers = append(headers, &tar.Header{
+			Name:     fmt.Sprintf("usr/share/%d/%d/file-%d", i%100, i%1000, i),
+			Typeflag: tar.TypeReg,
+		})
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		merger := NewMerger(nil)
+		for idx := 0; idx < 2; idx++ {
+			for _, hdr := range headers {
+				merger.Apply(idx, hdr)
+			}
+		}
+	}
+}
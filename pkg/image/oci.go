@@ -0,0 +1,173 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/image/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// ociIndexFile is the name of the index at the root of an OCI image layout
+	ociIndexFile = "index.json"
+	// ociBlobsDir is the directory containing the content addressable blobs of an OCI image layout
+	ociBlobsDir = "blobs"
+)
+
+// ociImage is an Image read from an OCI image layout directory
+type ociImage struct {
+	configImage
+	// dir is the root of the OCI image layout
+	dir string
+}
+
+// NewOCIImage will construct a new Image from the OCI image layout located in dir.
+// If the layout contains multiple images, tag is used to select the image
+// by its 'org.opencontainers.image.ref.name' annotation.
+func NewOCIImage(dir string, tag string) (Image, error) {
+	if _, err := os.Stat(filepath.Join(dir, ocispec.ImageLayoutFile)); err != nil {
+		logrus.Errorf("failure reading oci layout file: %s", err)
+		return nil, errors.New("directory is not an OCI image layout")
+	}
+
+	var index ocispec.Index
+	if err := readJSONFile(filepath.Join(dir, ociIndexFile), &index); err != nil {
+		logrus.Errorf("failure reading oci index: %s", err)
+		return nil, errors.New("invalid OCI image layout")
+	}
+
+	img := &ociImage{dir: dir}
+	desc, err := selectOCIManifest(index.Manifests, tag)
+	if err != nil {
+		return nil, err
+	}
+	img.name = desc.Annotations[ocispec.AnnotationRefName]
+	if img.name == "" {
+		img.name = filepath.Base(dir)
+	}
+
+	// resolve any nested indexes down to a single image manifest, up to maxIndexDepth indexes
+	for depth := 0; isIndex(desc.MediaType); depth++ {
+		if depth == maxIndexDepth {
+			return nil, fmt.Errorf("image indexes nested more than %d deep", maxIndexDepth)
+		}
+		logrus.Debugf("resolving nested index %s", desc.Digest)
+		var nested ocispec.Index
+		if err = img.readBlobJSON(desc.Digest, &nested); err != nil {
+			return nil, err
+		}
+		if desc, err = selectPlatformManifest(nested.Manifests); err != nil {
+			return nil, err
+		}
+	}
+
+	var manifest ocispec.Manifest
+	if err = img.readBlobJSON(desc.Digest, &manifest); err != nil {
+		return nil, err
+	}
+	img.digest = desc.Digest.String()
+	if err = img.readBlobJSON(manifest.Config.Digest, &img.config); err != nil {
+		return nil, err
+	}
+
+	for idx, l := range manifest.Layers {
+		if err = l.Digest.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid layer digest '%s': %w", l.Digest, err)
+		}
+		blob := img.blobPath(l.Digest)
+		img.layers = append(img.layers, layer{
+			digest:    img.diffID(idx, l.Digest.String()),
+			createdBy: img.createdBy(idx),
+			open: func() (io.ReadCloser, error) {
+				return os.Open(blob)
+			},
+		})
+	}
+	return img, nil
+}
+
+// SplitOCIDir will split a reference to an image in an OCI image layout
+// in the form 'path[:tag]' into the directory and tag
+func SplitOCIDir(value string) (dir string, tag string) {
+	idx := strings.LastIndex(value, ":")
+	// a separator within the path (i.e. a windows volume) is not a tag
+	if idx < 0 || strings.ContainsAny(value[idx+1:], `/\`) {
+		return value, ""
+	}
+	return value[:idx], value[idx+1:]
+}
+
+// blobPath will return the path of the blob with the given digest
+func (o *ociImage) blobPath(d digest.Digest) string {
+	return filepath.Join(o.dir, ociBlobsDir, d.Algorithm().String(), d.Encoded())
+}
+
+// readBlobJSON will decode the JSON blob with the given digest into v
+func (o *ociImage) readBlobJSON(d digest.Digest, v any) error {
+	if err := d.Validate(); err != nil {
+		return fmt.Errorf("invalid digest '%s': %w", d, err)
+	}
+	if err := readJSONFile(o.blobPath(d), v); err != nil {
+		logrus.Errorf("failure reading blob %s: %s", d, err)
+		return fmt.Errorf("unable to read blob %s", d)
+	}
+	return nil
+}
+
+// selectOCIManifest will return the descriptor annotated with the given tag,
+// or the only descriptor if tag is empty
+func selectOCIManifest(manifests []ocispec.Descriptor, tag string) (ocispec.Descriptor, error) {
+	if len(manifests) == 0 {
+		return ocispec.Descriptor{}, errors.New("OCI image layout contains no images")
+	}
+	if tag == "" {
+		if len(manifests) > 1 {
+			logrus.Warnf("OCI image layout contains %d images, using the first", len(manifests))
+		}
+		return manifests[0], nil
+	}
+	for _, m := range manifests {
+		if m.Annotations[ocispec.AnnotationRefName] == tag {
+			return m, nil
+		}
+	}
+	return ocispec.Descriptor{}, fmt.Errorf("OCI image layout does not contain image '%s'", tag)
+}
+
+// selectPlatformManifest will return the descriptor for the platform
+// of the current machine, or the first descriptor if none match
+func selectPlatformManifest(manifests []ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if len(manifests) == 0 {
+		return ocispec.Descriptor{}, errors.New("image index contains no manifests")
+	}
+	for _, m := range manifests {
+		if m.Platform != nil && m.Platform.OS == runtime.GOOS && m.Platform.Architecture == runtime.GOARCH {
+			return m, nil
+		}
+	}
+	logrus.Warnf("no manifest found for platform %s/%s, using the first", runtime.GOOS, runtime.GOARCH)
+	return manifests[0], nil
+}
+
+// isIndex will return true if the media type is for an index of manifests
+func isIndex(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == registry.DockerManifestListMediaType
+}
+
+// readJSONFile will decode the JSON file located at path into v
+func readJSONFile(path string, v any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
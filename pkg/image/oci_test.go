@@ -0,0 +1,172 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testOCILayout is an OCI image layout in a temporary directory
+type testOCILayout struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestOCILayout(t *testing.T) *testOCILayout {
+	t.Helper()
+	l := &testOCILayout{t: t, dir: t.TempDir()}
+	l.writeJSON(ocispec.ImageLayoutFile, ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	return l
+}
+
+// writeJSON will write v encoded as JSON to the file at name in the layout
+func (l *testOCILayout) writeJSON(name string, v any) []byte {
+	l.t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(l.dir, name), raw, 0o644); err != nil {
+		l.t.Fatal(err)
+	}
+	return raw
+}
+
+// writeBlob will add the content as a blob of the layout, returning its descriptor
+func (l *testOCILayout) writeBlob(mediaType string, content []byte) ocispec.Descriptor {
+	l.t.Helper()
+	d := digest.FromBytes(content)
+	dir := filepath.Join(l.dir, ociBlobsDir, d.Algorithm().String())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		l.t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, d.Encoded()), content, 0o644); err != nil {
+		l.t.Fatal(err)
+	}
+	return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(content))}
+}
+
+// writeBlobJSON will add v encoded as JSON as a blob of the layout, returning its descriptor
+func (l *testOCILayout) writeBlobJSON(mediaType string, v any) ocispec.Descriptor {
+	l.t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	return l.writeBlob(mediaType, raw)
+}
+
+// writeImage will add the blobs of an image with a single layer containing the file,
+// returning the descriptor of its manifest and the diff ID of the layer
+func (l *testOCILayout) writeImage(file string) (ocispec.Descriptor, digest.Digest) {
+	l.t.Helper()
+	var layerTar bytes.Buffer
+	writeTestTar(l.t, &layerTar, []testArchiveFile{{file, []byte("TOKEN=secret\n")}})
+	layerDesc := l.writeBlob(ocispec.MediaTypeImageLayer, layerTar.Bytes())
+	config := l.writeBlobJSON(ocispec.MediaTypeImageConfig, ocispec.Image{
+		Config:  ocispec.ImageConfig{Env: []string{"API_KEY=abc123"}},
+		RootFS:  ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{layerDesc.Digest}},
+		History: []ocispec.History{{CreatedBy: "COPY " + file}},
+	})
+	manifest := l.writeBlobJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	})
+	return manifest, layerDesc.Digest
+}
+
+// tagged will return the descriptor annotated with the tag
+func tagged(desc ocispec.Descriptor, tag string) ocispec.Descriptor {
+	desc.Annotations = map[string]string{ocispec.AnnotationRefName: tag}
+	return desc
+}
+
+func TestOCIImage(t *testing.T) {
+	layout := newTestOCILayout(t)
+	v1, v1Layer := layout.writeImage("app/.env")
+	v2, v2Layer := layout.writeImage("srv/.env")
+	// the second image is only referenced through a nested index
+	nested := layout.writeBlobJSON(ocispec.MediaTypeImageIndex, ocispec.Index{Manifests: []ocispec.Descriptor{v2}})
+	layout.writeJSON(ociIndexFile, ocispec.Index{
+		Manifests: []ocispec.Descriptor{tagged(v1, "v1"), tagged(nested, "v2")},
+	})
+
+	var testCases = []struct {
+		desc     string
+		tag      string
+		name     string
+		manifest digest.Digest
+		layer    digest.Digest
+	}{
+		{"first image", "", "v1", v1.Digest, v1Layer},
+		{"tagged image", "v1", "v1", v1.Digest, v1Layer},
+		{"nested index", "v2", "v2", v2.Digest, v2Layer},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			img, err := NewOCIImage(layout.dir, tc.tag)
+			if err != nil {
+				t.Fatalf("unexpected error opening layout: %s", err)
+			}
+			if d, _ := img.Digest(); img.Name() != tc.name || d != tc.manifest.String() {
+				t.Errorf("expected image %s with digest %s, got %s with digest %s", tc.name, tc.manifest, img.Name(), d)
+			}
+			layers, _ := img.Layers()
+			if len(layers) != 1 || layers[0].Digest() != tc.layer.String() {
+				t.Fatalf("expected a single layer %s, got %d layers", tc.layer, len(layers))
+			}
+			envVars, _ := img.ParseEnvVars()
+			if len(envVars) != 1 || envVars[0].Name != "API_KEY" {
+				t.Errorf("unexpected env vars %+v", envVars)
+			}
+		})
+	}
+
+	if _, err := NewOCIImage(layout.dir, "v3"); err == nil {
+		t.Errorf("expected an error for a tag not in the layout")
+	}
+	if _, err := NewOCIImage(t.TempDir(), ""); err == nil {
+		t.Errorf("expected an error for a directory without an OCI layout file")
+	}
+}
+
+func TestOCIImageNestedIndexes(t *testing.T) {
+	layout := newTestOCILayout(t)
+	desc, _ := layout.writeImage("app/.env")
+	for i := 0; i <= maxIndexDepth; i++ {
+		desc = layout.writeBlobJSON(ocispec.MediaTypeImageIndex, ocispec.Index{Manifests: []ocispec.Descriptor{desc}})
+	}
+	layout.writeJSON(ociIndexFile, ocispec.Index{Manifests: []ocispec.Descriptor{desc}})
+
+	if _, err := NewOCIImage(layout.dir, ""); err == nil {
+		t.Errorf("expected an error for indexes nested more than %d deep", maxIndexDepth)
+	}
+}
+
+func TestSplitOCIDir(t *testing.T) {
+	var testCases = []struct {
+		value string
+		dir   string
+		tag   string
+	}{
+		{"image", "image", ""},
+		{"./out/image:v1", "./out/image", "v1"},
+		{"/tmp/image:", "/tmp/image", ""},
+		// a separator within the path is not a tag
+		{`C:\images\app`, `C:\images\app`, ""},
+		{`C:\images\app:latest`, `C:\images\app`, "latest"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			dir, tag := SplitOCIDir(tc.value)
+			if dir != tc.dir || tag != tc.tag {
+				t.Errorf("expected directory '%s' and tag '%s', got '%s' and '%s'", tc.dir, tc.tag, dir, tag)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/distribution/registry/client/auth/challenge"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenResponse is the response of a registry token service
+type tokenResponse struct {
+	// Token is the bearer token to use for requests
+	Token string `json:"token"`
+	// AccessToken is the OAuth 2.0 compatible name of Token
+	AccessToken string `json:"access_token"`
+}
+
+// authenticate will respond to the authentication challenge of the response
+// and return the value of the authorization header to retry the request with
+func (c *Client) authenticate(ctx context.Context, resp *http.Response, scope string) (string, error) {
+	for _, ch := range challenge.ResponseChallenges(resp) {
+		switch strings.ToLower(ch.Scheme) {
+		case "bearer":
+			token, err := c.fetchToken(ctx, ch.Parameters, scope)
+			if err != nil {
+				return "", err
+			}
+			c.mu.Lock()
+			c.tokens[scope] = token
+			c.mu.Unlock()
+			return "Bearer " + token, nil
+		case "basic":
+			if c.opts.Username == "" {
+				return "", errors.New("registry requires credentials")
+			}
+			req := http.Request{Header: make(http.Header)}
+			req.SetBasicAuth(c.opts.Username, c.opts.Password)
+			basic := req.Header.Get("Authorization")
+			c.mu.Lock()
+			c.basic = basic
+			c.mu.Unlock()
+			return basic, nil
+		}
+	}
+	return "", errors.New("unsupported registry authentication")
+}
+
+// fetchToken will request a bearer token for the scope from the
+// token service described by the challenge parameters
+func (c *Client) fetchToken(ctx context.Context, params map[string]string, scope string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm '%s'", params["realm"])
+	}
+
+	query := realm.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	// prefer the scope requested by the registry
+	if s, ok := params["scope"]; ok {
+		scope = s
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.opts.Username != "" {
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+
+	logrus.Debugf("requesting registry token from %s", realm.Host)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		logrus.Errorf("failure requesting registry token: %s", err)
+		return "", errors.New("unable to authenticate with registry")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logrus.Errorf("token service responded with status %s", resp.Status)
+		return "", errors.New("unable to authenticate with registry")
+	}
+
+	var token tokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", errors.New("token service returned no token")
+	}
+	return token.Token, nil
+}
@@ -0,0 +1,197 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// DockerManifestMediaType is the media type of a docker image manifest
+	DockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	// DockerManifestListMediaType is the media type of a docker multi-platform manifest list
+	DockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// manifestMediaTypes are the media types of manifests the client accepts
+var manifestMediaTypes = []string{
+	ocispec.MediaTypeImageManifest,
+	ocispec.MediaTypeImageIndex,
+	DockerManifestMediaType,
+	DockerManifestListMediaType,
+}
+
+// dockerHubDomain is the domain used in image references for docker hub
+// and dockerHubRegistry is the host serving the registry API for it
+const (
+	dockerHubDomain   = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// Opts is used to configure a Client
+type Opts struct {
+	// Insecure will connect to the registry over plain HTTP
+	Insecure bool
+	// Username is the username used to authenticate with the registry
+	Username string
+	// Password is the password or token used to authenticate with the registry
+	Password string
+}
+
+// Client is a client for the [Distribution HTTP API] of an image registry
+//
+// [Distribution HTTP API]: https://distribution.github.io/distribution/spec/api/
+type Client struct {
+	// baseURL is the scheme and host of the registry
+	baseURL string
+	// opts are the options the client was configured with
+	opts Opts
+	// http is the underlying HTTP client
+	http *http.Client
+
+	// tokens are the bearer tokens received from the registry
+	// auth service, keyed by the scope they were granted for
+	tokens map[string]string
+	// basic is the basic authorization header accepted by the registry,
+	// which unlike tokens is valid for any scope of the registry host
+	basic string
+	// mu guards tokens and basic
+	mu sync.Mutex
+}
+
+// New will construct a new Client for the registry at the given host
+func New(host string, opts Opts) *Client {
+	if host == dockerHubDomain {
+		host = dockerHubRegistry
+	}
+	scheme := "https"
+	if opts.Insecure {
+		scheme = "http"
+	}
+	return &Client{
+		baseURL: fmt.Sprintf("%s://%s", scheme, host),
+		opts:    opts,
+		http:    http.DefaultClient,
+		tokens:  make(map[string]string),
+	}
+}
+
+// Manifest will fetch the manifest for the reference (tag or digest) of the
+// repository, returning the raw manifest and its media type
+func (c *Client) Manifest(ctx context.Context, repository, reference string) ([]byte, string, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL, repository, reference)
+	resp, err := c.get(ctx, repository, url, strings.Join(manifestMediaTypes, ", "))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	// verify the manifest if it was requested by digest
+	if d, err := digest.Parse(reference); err == nil && d.Algorithm().FromBytes(body) != d {
+		return nil, "", fmt.Errorf("manifest does not match digest %s", d)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// Blob will open the blob with the given digest from the repository. The content is
+// verified against the digest as it is read, and reading fails at the end of the blob
+// if it does not match. The caller is responsible for closing the returned reader
+func (c *Client) Blob(ctx context.Context, repository string, d digest.Digest) (io.ReadCloser, error) {
+	if err := d.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid blob digest '%s': %w", d, err)
+	}
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL, repository, d)
+	resp, err := c.get(ctx, repository, url, "")
+	if err != nil {
+		return nil, err
+	}
+	return &verifiedBlob{ReadCloser: resp.Body, digest: d, verifier: d.Verifier()}, nil
+}
+
+// verifiedBlob verifies the content of a blob against its digest as it is read
+type verifiedBlob struct {
+	io.ReadCloser
+	digest   digest.Digest
+	verifier digest.Verifier
+}
+
+func (b *verifiedBlob) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.verifier.Write(p[:n])
+	if err == io.EOF && !b.verifier.Verified() {
+		logrus.Errorf("blob does not match digest %s", b.digest)
+		return n, fmt.Errorf("blob does not match digest %s", b.digest)
+	}
+	return n, err
+}
+
+// get will perform a GET request against the registry,
+// authenticating with the registry if it is challenged to
+func (c *Client) get(ctx context.Context, repository, url, accept string) (*http.Response, error) {
+	scope := fmt.Sprintf("repository:%s:pull", repository)
+	resp, err := c.do(ctx, url, accept, c.authorization(scope))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		_ = resp.Body.Close()
+		logrus.Debugf("registry requested authentication for %s", url)
+		auth, err := c.authenticate(ctx, resp, scope)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(ctx, url, accept, auth); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		logrus.Errorf("registry responded to %s with status %s", url, resp.Status)
+		return nil, fmt.Errorf("unexpected registry response: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// do will perform a single GET request with the given headers
+func (c *Client) do(ctx context.Context, url, accept, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		logrus.Errorf("failure requesting %s: %s", url, err)
+		return nil, errors.New("unable to connect to registry")
+	}
+	return resp, nil
+}
+
+// authorization will return the value of the authorization header for a
+// previously granted scope, or the basic authorization header if the registry accepted one
+func (c *Client) authorization(scope string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if token, ok := c.tokens[scope]; ok {
+		return "Bearer " + token
+	}
+	return c.basic
+}
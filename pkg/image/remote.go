@@ -0,0 +1,145 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/image/registry"
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+	"io"
+)
+
+// maxIndexDepth is the maximum amount of nested image indexes (or manifest lists)
+// resolved to find the manifest of an image, so a cyclic index cannot loop forever
+const maxIndexDepth = 8
+
+// remoteImage is an Image read directly from a registry
+// via the Distribution HTTP API, without a docker daemon.
+// Layers are only downloaded once they are opened.
+type remoteImage struct {
+	configImage
+	// ref is the parsed image reference supplied by the user
+	ref reference.Named
+	// client is the client for the registry hosting the image
+	client *registry.Client
+	// ctx is the global context for all registry requests
+	ctx context.Context
+}
+
+// NewRegistryImage will construct a new Image by fetching the manifest
+// and configuration of the image directly from its registry
+func NewRegistryImage(name string, opts registry.Opts) (Image, error) {
+	ref, err := reference.ParseDockerRef(name)
+	if err != nil {
+		logrus.Errorf("failure parsing docker name: %s", err)
+		return nil, errors.New("invalid docker name")
+	}
+
+	img := &remoteImage{
+		ref:    ref,
+		client: registry.New(reference.Domain(ref), opts),
+		ctx:    context.Background(),
+	}
+
+	var tagOrDigest string
+	switch r := ref.(type) {
+	case reference.Canonical:
+		tagOrDigest = r.Digest().String()
+	case reference.Tagged:
+		tagOrDigest = r.Tag()
+	}
+
+	manifest, manifestDigest, err := img.resolveManifest(tagOrDigest)
+	if err != nil {
+		return nil, err
+	}
+	img.name, img.digest = reference.FamiliarString(ref), manifestDigest.String()
+
+	if err = img.readConfig(manifest.Config.Digest); err != nil {
+		return nil, err
+	}
+
+	for idx, l := range manifest.Layers {
+		d := l.Digest
+		img.layers = append(img.layers, layer{
+			digest:    img.diffID(idx, d.String()),
+			createdBy: img.createdBy(idx),
+			open: func() (io.ReadCloser, error) {
+				logrus.Debugf("downloading layer %s", d)
+				return img.client.Blob(img.ctx, reference.Path(img.ref), d)
+			},
+		})
+	}
+	return img, nil
+}
+
+// Pull does nothing, since layers are fetched from the registry when opened
+func (r *remoteImage) Pull() error {
+	logrus.Debug("registry images are read directly from the registry, skipping pull")
+	return nil
+}
+
+// resolveManifest will fetch the manifest for the tag or digest, resolving
+// any manifest lists to the manifest for the current platform, up to maxIndexDepth
+// nested lists. The digest of the resolved manifest is returned alongside it
+func (r *remoteImage) resolveManifest(tagOrDigest string) (ocispec.Manifest, digest.Digest, error) {
+	repository := reference.Path(r.ref)
+	for depth := 0; depth <= maxIndexDepth; depth++ {
+		raw, mediaType, err := r.client.Manifest(r.ctx, repository, tagOrDigest)
+		if err != nil {
+			logrus.Errorf("failure fetching manifest for %s: %s", r.ref, err)
+			return ocispec.Manifest{}, "", errors.New("unable to fetch image manifest")
+		}
+
+		// registries may not set the content type, fallback to the media type of the body
+		var body struct {
+			MediaType string `json:"mediaType"`
+			ocispec.Index
+		}
+		if err = json.Unmarshal(raw, &body); err != nil {
+			return ocispec.Manifest{}, "", fmt.Errorf("invalid image manifest: %w", err)
+		}
+		if mediaType == "" || mediaType == "application/json" {
+			mediaType = body.MediaType
+		}
+
+		if !isIndex(mediaType) {
+			var manifest ocispec.Manifest
+			if err = json.Unmarshal(raw, &manifest); err != nil {
+				return ocispec.Manifest{}, "", fmt.Errorf("invalid image manifest: %w", err)
+			}
+			return manifest, digest.FromBytes(raw), nil
+		}
+
+		desc, err := selectPlatformManifest(body.Manifests)
+		if err != nil {
+			return ocispec.Manifest{}, "", err
+		}
+		logrus.Debugf("resolved manifest list to %s", desc.Digest)
+		tagOrDigest = desc.Digest.String()
+	}
+	return ocispec.Manifest{}, "", fmt.Errorf("manifest lists nested more than %d deep", maxIndexDepth)
+}
+
+// readConfig will fetch and verify the image configuration blob
+func (r *remoteImage) readConfig(d digest.Digest) error {
+	rc, err := r.client.Blob(r.ctx, reference.Path(r.ref), d)
+	if err != nil {
+		logrus.Errorf("failure fetching image configuration: %s", err)
+		return errors.New("unable to fetch image configuration")
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if d.Algorithm().FromBytes(raw) != d {
+		return fmt.Errorf("image configuration does not match digest %s", d)
+	}
+	return json.Unmarshal(raw, &r.config)
+}
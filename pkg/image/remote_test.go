@@ -0,0 +1,207 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/bthuilot/dockerleaks/pkg/image/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testRegistry is an in-process stand in for a registry serving
+// a single image, requiring bearer token authentication
+type testRegistry struct {
+	*httptest.Server
+	manifest []byte
+	// indexes are image indexes and manifests served by their reference
+	indexes     map[string][]byte
+	blobs       map[digest.Digest][]byte
+	layerDigest digest.Digest
+	layerGets   int32
+}
+
+const testRegistryToken = "test-token"
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	var layerTar bytes.Buffer
+	tw := tar.NewWriter(&layerTar)
+	content := []byte("TOKEN=secret\n")
+	_ = tw.WriteHeader(&tar.Header{Name: "app/.env", Mode: 0o644, Size: int64(len(content))})
+	_, _ = tw.Write(content)
+	_ = tw.Close()
+	layerDigest := digest.FromBytes(layerTar.Bytes())
+
+	config, _ := json.Marshal(ocispec.Image{
+		Config: ocispec.ImageConfig{Env: []string{"PATH=/bin", "API_KEY=abc123"}},
+		RootFS: ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{layerDigest}},
+		History: []ocispec.History{
+			{CreatedBy: "ARG TOKEN"},
+			{CreatedBy: "|1 TOKEN=hunter2 /bin/sh -c echo hi"},
+		},
+	})
+	configDigest := digest.FromBytes(config)
+
+	manifest, _ := json.Marshal(ocispec.Manifest{
+		Config: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: configDigest, Size: int64(len(config))},
+		Layers: []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageLayer, Digest: layerDigest, Size: int64(layerTar.Len())}},
+	})
+
+	reg := &testRegistry{
+		manifest:    manifest,
+		indexes:     make(map[string][]byte),
+		layerDigest: layerDigest,
+		blobs: map[digest.Digest][]byte{
+			configDigest: config,
+			layerDigest:  layerTar.Bytes(),
+		},
+	}
+	reg.Server = httptest.NewServer(http.HandlerFunc(reg.serve))
+	t.Cleanup(reg.Close)
+	return reg
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": testRegistryToken})
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer "+testRegistryToken {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case req.URL.Path == "/v2/test/app/manifests/v1":
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		_, _ = w.Write(r.manifest)
+	case r.indexes[strings.TrimPrefix(req.URL.Path, "/v2/test/app/manifests/")] != nil:
+		// the media type is read from the body of the manifest
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(r.indexes[strings.TrimPrefix(req.URL.Path, "/v2/test/app/manifests/")])
+	case strings.HasPrefix(req.URL.Path, "/v2/test/app/blobs/"):
+		d := digest.Digest(strings.TrimPrefix(req.URL.Path, "/v2/test/app/blobs/"))
+		blob, ok := r.blobs[d]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if d == r.layerDigest {
+			atomic.AddInt32(&r.layerGets, 1)
+		}
+		_, _ = w.Write(blob)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRegistryImage(t *testing.T) {
+	reg := newTestRegistry(t)
+	name := strings.TrimPrefix(reg.URL, "http://") + "/test/app:v1"
+
+	img, err := NewRegistryImage(name, registry.Opts{Insecure: true})
+	if err != nil {
+		t.Fatalf("unexpected error creating image: %s", err)
+	}
+
+	envVars, err := img.ParseEnvVars()
+	if err != nil {
+		t.Fatalf("unexpected error parsing env vars: %s", err)
+	}
+	if len(envVars) != 2 || envVars[1].Name != "API_KEY" || envVars[1].Value != "abc123" {
+		t.Errorf("unexpected env vars %+v", envVars)
+	}
+
+	buildArgs, err := img.ParseBuildArguments()
+	if err != nil {
+		t.Fatalf("unexpected error parsing build args: %s", err)
+	}
+	if len(buildArgs) != 1 || buildArgs[0].Name != "TOKEN" || buildArgs[0].Value != "hunter2" {
+		t.Errorf("unexpected build args %+v", buildArgs)
+	}
+
+	if gets := atomic.LoadInt32(&reg.layerGets); gets != 0 {
+		t.Errorf("expected no layers to be downloaded for a static scan, got %d", gets)
+	}
+
+	layers, err := img.Layers()
+	if err != nil || len(layers) != 1 {
+		t.Fatalf("expected a single layer, got %d (%v)", len(layers), err)
+	}
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		t.Fatalf("unexpected error opening layer: %s", err)
+	}
+	defer rc.Close()
+	hdr, err := tar.NewReader(rc).Next()
+	if err != nil || hdr.Name != "app/.env" {
+		t.Errorf("unexpected layer contents %v (%v)", hdr, err)
+	}
+	if _, err = io.Copy(io.Discard, rc); err != nil {
+		t.Errorf("unexpected error reading layer: %s", err)
+	}
+	if gets := atomic.LoadInt32(&reg.layerGets); gets != 1 {
+		t.Errorf("expected the layer to be downloaded once, got %d", gets)
+	}
+}
+
+func TestRegistryImageMissing(t *testing.T) {
+	reg := newTestRegistry(t)
+	name := strings.TrimPrefix(reg.URL, "http://") + "/test/missing:v1"
+
+	if _, err := NewRegistryImage(name, registry.Opts{Insecure: true}); err == nil {
+		t.Errorf("expected error fetching missing image")
+	}
+}
+
+func TestRegistryImageTamperedLayer(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.blobs[reg.layerDigest] = append([]byte{}, reg.blobs[reg.layerDigest]...)
+	copy(reg.blobs[reg.layerDigest][512:], "TOKEN=forged")
+	name := strings.TrimPrefix(reg.URL, "http://") + "/test/app:v1"
+
+	img, err := NewRegistryImage(name, registry.Opts{Insecure: true})
+	if err != nil {
+		t.Fatalf("unexpected error creating image: %s", err)
+	}
+	layers, err := img.Layers()
+	if err != nil || len(layers) != 1 {
+		t.Fatalf("expected a single layer, got %d (%v)", len(layers), err)
+	}
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		t.Fatalf("unexpected error opening layer: %s", err)
+	}
+	defer rc.Close()
+	if _, err = io.Copy(io.Discard, rc); err == nil {
+		t.Errorf("expected error reading a layer not matching its digest")
+	}
+}
+
+func TestRegistryImageNestedIndexes(t *testing.T) {
+	reg := newTestRegistry(t)
+	// each index points to the next, more deeply than is resolved, ending at the manifest
+	next := digest.FromBytes(reg.manifest)
+	reg.indexes[next.String()] = reg.manifest
+	for i := 0; i <= maxIndexDepth+1; i++ {
+		index, _ := json.Marshal(ocispec.Index{
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageIndex, Digest: next}},
+		})
+		next = digest.FromBytes(index)
+		reg.indexes[next.String()] = index
+	}
+	name := strings.TrimPrefix(reg.URL, "http://") + "/test/app@" + next.String()
+
+	if _, err := NewRegistryImage(name, registry.Opts{Insecure: true}); err == nil {
+		t.Errorf("expected error resolving deeply nested indexes")
+	}
+}
@@ -5,9 +5,19 @@ import (
 	"os"
 )
 
+const (
+	// ExitClean is the exit code of a scan without findings
+	ExitClean = 0
+	// ExitFindings is the exit code of a scan with findings
+	ExitFindings = 1
+	// ExitError is the exit code of a scan that could not be completed
+	ExitError = 2
+)
+
+// Fatal will print the message and exit with ExitError
 func Fatal(format string, a ...any) {
 	_, _ = fmt.Fprintf(os.Stderr, format, a...)
-	os.Exit(1)
+	os.Exit(ExitError)
 }
 
 func Msg(format string, args ...any) {
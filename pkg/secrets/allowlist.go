@@ -0,0 +1,169 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/bthuilot/dockerleaks/internal/config"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+)
+
+// Allowlist represents a set of conditions under
+// which a match of a rule is not a secret
+type Allowlist struct {
+	// Description is a human-readable description of the allowlist
+	Description string `json:"description,omitempty"`
+	// Regexes are regular expressions matching secrets that are allowed
+	Regexes []*regexp.Regexp `json:"regexes,omitempty"`
+	// MatchRegexes are regular expressions matching the full
+	// match of the rule, rather than only the secret
+	MatchRegexes []*regexp.Regexp `json:"match_regexes,omitempty"`
+	// Paths are regular expressions matching file paths that are allowed
+	Paths []*regexp.Regexp `json:"paths,omitempty"`
+	// Variables are regular expressions matching the names of
+	// environment variables and build arguments that are allowed
+	Variables []*regexp.Regexp `json:"variables,omitempty"`
+	// Hashes are the hex encoded SHA-256 hashes of secrets that are allowed
+	Hashes []string `json:"hashes,omitempty"`
+	// StopWords are strings that, if contained in the secret, allow it
+	StopWords []string `json:"stop_words,omitempty"`
+}
+
+// candidate is a match of a rule checked against allowlists
+type candidate struct {
+	// path is the path of the file the match was found in, if any
+	path string
+	// variable is the name of the variable the match was found in, if any
+	variable string
+	// secret is the secret of the match
+	secret string
+	// match is the full match of the rule
+	match string
+}
+
+// allowsPath will return true if the path of a file is allowed
+func (a Allowlist) allowsPath(path string) bool {
+	for _, p := range a.Paths {
+		if p.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsVariable will return true if the name of a variable is allowed
+func (a Allowlist) allowsVariable(name string) bool {
+	for _, v := range a.Variables {
+		if v.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsSecret will return true if the secret, or the full match it was found in, is allowed
+func (a Allowlist) allowsSecret(secret, match string) bool {
+	for _, r := range a.Regexes {
+		if r.MatchString(secret) {
+			return true
+		}
+	}
+	for _, r := range a.MatchRegexes {
+		if r.MatchString(match) {
+			return true
+		}
+	}
+	if len(a.Hashes) > 0 {
+		sum := sha256.Sum256([]byte(secret))
+		hash := hex.EncodeToString(sum[:])
+		for _, h := range a.Hashes {
+			if strings.EqualFold(h, hash) {
+				return true
+			}
+		}
+	}
+	lower := strings.ToLower(secret)
+	for _, w := range a.StopWords {
+		if strings.Contains(lower, strings.ToLower(w)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows will return true if the candidate is allowed. Matches of rules
+// that only match the path of a file are only allowed by their path
+func (a Allowlist) allows(c candidate) bool {
+	return (c.path != "" && a.allowsPath(c.path)) ||
+		(c.variable != "" && a.allowsVariable(c.variable)) ||
+		(c.secret != "" && a.allowsSecret(c.secret, c.match))
+}
+
+// allowed will return true if any of the allowlists allow the candidate
+func allowed(allowlists []Allowlist, c candidate) bool {
+	for _, a := range allowlists {
+		if a.allows(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAllowlists will parse a list of user defined allowlists,
+// returning an error if any of their regular expressions are invalid
+func ParseAllowlists(userAllowlists []config.UserAllowlist) ([]Allowlist, error) {
+	allowlists := make([]Allowlist, 0, len(userAllowlists))
+	for _, a := range userAllowlists {
+		allowlist, err := parseAllowlist(a)
+		if err != nil {
+			return nil, err
+		}
+		allowlists = append(allowlists, allowlist)
+	}
+	return allowlists, nil
+}
+
+// ParseImageAllowlists will parse the user defined allowlists that apply to the image with the given name,
+// returning an error if any of their regular expressions are invalid
+func ParseImageAllowlists(userAllowlists []config.UserImageAllowlist, image string) ([]Allowlist, error) {
+	var allowlists []Allowlist
+	for _, a := range userAllowlists {
+		regex, err := regexp.Compile(a.Image)
+		if err != nil {
+			logrus.Errorf("unable to parse image regular expression `%s`: %s", a.Image, err)
+			return nil, errors.New("unable to parse image allowlist")
+		}
+		if !regex.MatchString(image) {
+			continue
+		}
+		allowlist, err := parseAllowlist(a.UserAllowlist)
+		if err != nil {
+			return nil, err
+		}
+		allowlists = append(allowlists, allowlist)
+	}
+	return allowlists, nil
+}
+
+// parseAllowlist will compile the regular expressions of a user defined allowlist
+func parseAllowlist(a config.UserAllowlist) (allowlist Allowlist, err error) {
+	name := a.Description
+	if name == "" {
+		name = "allowlist"
+	}
+	allowlist.Description = a.Description
+	if allowlist.Paths, err = compileAll(name, a.Paths); err != nil {
+		return allowlist, errors.New("unable to parse allowlist paths")
+	}
+	if allowlist.Variables, err = compileAll(name, a.Variables); err != nil {
+		return allowlist, errors.New("unable to parse allowlist variables")
+	}
+	if allowlist.Regexes, err = compileAll(name, a.Regexes); err != nil {
+		return allowlist, errors.New("unable to parse allowlist regexes")
+	}
+	allowlist.Hashes = a.Hashes
+	allowlist.StopWords = a.StopWords
+	return allowlist, nil
+}
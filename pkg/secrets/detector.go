@@ -3,6 +3,7 @@ package secrets
 import (
 	"github.com/sirupsen/logrus"
 	"io"
+	"sync/atomic"
 )
 
 // StaticDetector is the interface for a secrets detector.
@@ -11,13 +12,16 @@ type StaticDetector interface {
 	// SearchText searches for secrets in the given text.
 	// It returns a slice of matches and an error.
 	SearchText(text string) ([]TextMatch, error)
+	// SearchVariable searches for secrets in the value of the
+	// environment variable or build argument with the given name.
+	SearchVariable(name, value string) ([]TextMatch, error)
 }
 
 // DynamicDetector is the interface for a secrets detector.
 // It is used to search for secrets in a dynamic image's tarball.
 type DynamicDetector interface {
-	// SearchFile searches for secrets in the given file.
-	SearchFile(path string, body io.Reader) ([]FileMatch, error)
+	// SearchFile searches for secrets in the given file, which is size bytes large.
+	SearchFile(path string, size int64, body io.Reader) ([]FileMatch, error)
 }
 
 // Detector is the interface for a secrets detector.
@@ -25,6 +29,14 @@ type DynamicDetector interface {
 type Detector interface {
 	StaticDetector
 	DynamicDetector
+	// Rules will return every static and dynamic rule of the detector
+	Rules() []Rule
+	// Suppressed will return the amount of matches allowed by an allowlist
+	// in every search of the detector, which are not returned as matches
+	Suppressed() int
+	// Hash will return a hash of the rules and options of the detector,
+	// which changes whenever the matches found by the detector could change
+	Hash() string
 }
 
 // Opts is used to configure a Detector.
@@ -34,6 +46,31 @@ type Opts struct {
 
 	// UseDefaultDynamicRules will include the default rules in the Detector.
 	UseDefaultDynamicRules bool
+
+	// ChunkSize is the amount of bytes of a file searched at once,
+	// a value of 0 means that DefaultChunkSize is used.
+	ChunkSize int
+
+	// ChunkOverlap is the amount of bytes at the end of a chunk searched again
+	// at the start of the next chunk. Matches longer than the overlap spanning
+	// two chunks are not found. A value of 0 means that DefaultChunkOverlap is used.
+	ChunkOverlap int
+
+	// MaxFileSize is the size in bytes above which the content of a file
+	// is handled by OversizedFiles, a value of 0 means that there is no limit.
+	MaxFileSize int64
+
+	// ContextLines is the amount of lines before and after the line of a secret
+	// included in the context of a FileMatch, a negative value means no context is included.
+	ContextLines int
+
+	// OversizedFiles is how the content of a file larger than MaxFileSize is searched,
+	// an empty value means that SkipOversized is used.
+	OversizedFiles OversizedPolicy
+
+	// Allowlists are conditions under which a match of any rule is not a secret,
+	// applied in addition to the allowlists of each rule.
+	Allowlists []Allowlist
 }
 
 // NewDetector creates a new Detector with the given rules,
@@ -51,21 +88,169 @@ func NewDetector(opts Opts, staticRules []StaticRule, dynamicRules []DynamicRule
 		logrus.Debugf("using default dynamic rules")
 		baseDynamicRules = DefaultDynamicRules
 	}
-	return detector{
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.ChunkOverlap <= 0 {
+		opts.ChunkOverlap = DefaultChunkOverlap
+	}
+	if opts.ChunkOverlap >= opts.ChunkSize {
+		opts.ChunkOverlap = opts.ChunkSize / 2
+	}
+	if opts.OversizedFiles == "" {
+		opts.OversizedFiles = SkipOversized
+	}
+	d := detector{
+		opts:         opts,
 		staticRules:  append(baseStaticRules, staticRules...),
 		dynamicRules: append(baseDynamicRules, dynamicRules...),
+		suppressed:   new(int64),
 	}
+
+	// build the keyword prefilters once, since they are shared by every search
+	staticKeywords := make([][]string, len(d.staticRules))
+	for i, r := range d.staticRules {
+		staticKeywords[i] = r.Keywords
+	}
+	d.staticFilter = newKeywordFilter(staticKeywords)
+	dynamicKeywords := make([][]string, len(d.dynamicRules))
+	for i, r := range d.dynamicRules {
+		// rules matching only the file path do not search the content
+		if r.Pattern != nil {
+			dynamicKeywords[i] = r.Keywords
+		}
+	}
+	d.dynamicFilter = newKeywordFilter(dynamicKeywords)
+	return d
 }
 
 type detector struct {
+	opts         Opts
 	staticRules  []StaticRule
 	dynamicRules []DynamicRule
+	// staticFilter selects the static rules to check against a text
+	staticFilter keywordFilter
+	// dynamicFilter selects the dynamic rules to check against a file
+	dynamicFilter keywordFilter
+	// suppressed is the amount of matches allowed by an allowlist,
+	// shared between copies of the detector and updated atomically
+	suppressed *int64
+}
+
+func (d detector) Rules() []Rule {
+	rules := make([]Rule, 0, len(d.staticRules)+len(d.dynamicRules))
+	for _, r := range d.staticRules {
+		rules = append(rules, r)
+	}
+	for _, r := range d.dynamicRules {
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func (d detector) Suppressed() int {
+	return int(atomic.LoadInt64(d.suppressed))
 }
 
 func (d detector) SearchText(text string) (matches []TextMatch, err error) {
-	return findStaticRuleMatches(text, d.staticRules)
+	return d.SearchVariable("", text)
+}
+
+func (d detector) SearchVariable(name, value string) (matches []TextMatch, err error) {
+	indexes := d.staticFilter.rules(value)
+	rules := make([]StaticRule, 0, len(indexes))
+	for _, i := range indexes {
+		rules = append(rules, d.staticRules[i])
+	}
+	found, err := findStaticRuleMatches(value, rules)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range found {
+		c := candidate{variable: name, secret: m.Secret.Value, match: m.match}
+		if !d.allowed(m.Rule.Allowlists, c) {
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+func (d detector) SearchFile(path string, size int64, body io.Reader) (matches []FileMatch, err error) {
+	pathMatches, contentRules := findPathRuleMatches(path, d.dynamicRules)
+	matches = d.allowedFileMatches(pathMatches)
+	searchContent := false
+	for _, ok := range contentRules {
+		searchContent = searchContent || ok
+	}
+	if !searchContent {
+		// the content is not read if no rule can match it
+		return matches, nil
+	}
+
+	// the size is known before the content is read, so no part of an oversized file is searched
+	if d.opts.MaxFileSize > 0 && size > d.opts.MaxFileSize && d.opts.OversizedFiles == SkipOversized {
+		logrus.Warnf("skipping content of %s, larger than the maximum file size of %d bytes", path, d.opts.MaxFileSize)
+		return matches, nil
+	}
+
+	logrus.Debugf("checking content of file %s", path)
+	var (
+		contentMatches []FileMatch
+		// seen are the matches found in the previous chunk, since the
+		// same match is found again if it is within the overlap
+		seen  = make(map[chunkMatch]bool)
+		lines = lineCounter{lastNewline: -1}
+	)
+	err = scanChunks(body, d.opts.ChunkSize, d.opts.ChunkOverlap, d.opts.MaxFileSize, func(chunk string, offset int64) {
+		var rules []DynamicRule
+		for _, i := range d.dynamicFilter.rules(chunk) {
+			if contentRules[i] {
+				rules = append(rules, d.dynamicRules[i])
+			}
+		}
+		lines.next(chunk, offset)
+		current := make(map[chunkMatch]bool)
+		for _, m := range findDynamicRuleMatches(path, chunk, offset, rules) {
+			m.Line, m.Column = lines.position(m.StartPos)
+			m.Context = extractSnippet(chunk, int(m.StartPos-offset), int(m.EndPos-offset), m.Line, d.opts.ContextLines)
+			key := chunkMatch{rule: m.Rule.String(), secret: m.Secret.Value, start: m.StartPos}
+			current[key] = true
+			if !seen[key] {
+				contentMatches = append(contentMatches, m)
+			}
+		}
+		seen = current
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(matches, d.allowedFileMatches(contentMatches)...), nil
+}
+
+// allowedFileMatches will return the matches in a file not allowed by an allowlist
+func (d detector) allowedFileMatches(found []FileMatch) (matches []FileMatch) {
+	for _, m := range found {
+		c := candidate{path: m.Path, secret: m.Secret.Value, match: m.match}
+		if !d.allowed(m.Rule.Allowlists, c) {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// allowed will return true if the candidate is allowed by the allowlists of its rule
+// or the allowlists of the detector, counting it as suppressed if so
+func (d detector) allowed(ruleAllowlists []Allowlist, c candidate) bool {
+	if allowed(ruleAllowlists, c) || allowed(d.opts.Allowlists, c) {
+		atomic.AddInt64(d.suppressed, 1)
+		return true
+	}
+	return false
 }
 
-func (d detector) SearchFile(path string, body io.Reader) (matches []FileMatch, err error) {
-	return findDynamicRuleMatches(path, body, d.dynamicRules)
+// chunkMatch identifies a match found in a chunk of a file
+type chunkMatch struct {
+	rule   string
+	secret string
+	start  int64
 }
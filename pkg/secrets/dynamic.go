@@ -3,6 +3,28 @@ package secrets
 import (
 	"github.com/sirupsen/logrus"
 	"io"
+	"strings"
+)
+
+const (
+	// DefaultChunkSize is the default amount of bytes of a file searched at once
+	DefaultChunkSize = 1024 * 1024
+	// DefaultChunkOverlap is the default amount of bytes at the end of a chunk
+	// that are searched again at the start of the next chunk, so that matches
+	// spanning the boundary of two chunks are still found
+	DefaultChunkOverlap = 4 * 1024
+)
+
+// OversizedPolicy is how the content of a file larger than
+// the maximum file size of a Detector is searched
+type OversizedPolicy string
+
+const (
+	// SkipOversized will not report any matches in the content of oversized files,
+	// only the rules matching the path of the file are checked
+	SkipOversized OversizedPolicy = "skip"
+	// ScanOversized will search only the first maximum file size bytes of oversized files
+	ScanOversized OversizedPolicy = "scan"
 )
 
 // FileMatch represents a match of string that is detected to be a secret value
@@ -13,25 +35,31 @@ type FileMatch struct {
 	Secret Secret
 	// Path is the path of the file that was searched
 	Path string
-	//// StartPos is the starting position of the match
-	//StartPos int
-	//// EndPos is the ending position of the match
-	//EndPos int
+	// StartPos is the starting byte offset of the secret in the file
+	StartPos int64
+	// EndPos is the ending byte offset of the secret in the file
+	EndPos int64
+	// Line is the line of the file the secret starts on, starting from 1.
+	// a value of 0 means that the rule only matched the path of the file
+	Line int
+	// Column is the byte offset of the start of the secret in Line, starting from 1
+	Column int
+	// Context is the lines surrounding the secret, which may be cut short
+	// at the start of the chunk the secret was found in. Secrets are not masked
+	Context *Snippet
+	// match is the full match of the rule, which contains the secret
+	match string
 }
 
-func findDynamicRuleMatches(path string, body io.Reader, rules []DynamicRule) (matches []FileMatch, err error) {
-	// TODO: fix this to only read once for all rules, but dont read at all if not needed
-	var content []byte
-	if content, err = io.ReadAll(body); err != nil {
-		return nil, err
-	}
-
-	for _, r := range rules {
+// findPathRuleMatches will return the indexes of the rules that apply to the
+// file at path, and a match for each rule that only matches the path of a file
+func findPathRuleMatches(path string, rules []DynamicRule) (matches []FileMatch, contentRules []bool) {
+	contentRules = make([]bool, len(rules))
+	for i, r := range rules {
 		logrus.Debugf("checking rule %s with %s", r.Name, r.FilePattern)
 		if r.FilePattern != nil && !r.FilePattern.MatchString(path) {
 			continue
 		}
-
 		// If the pattern is nil, we only need to check if the file matches
 		if r.Pattern == nil {
 			matches = append(matches, FileMatch{
@@ -40,25 +68,106 @@ func findDynamicRuleMatches(path string, body io.Reader, rules []DynamicRule) (m
 			})
 			continue
 		}
+		contentRules[i] = true
+	}
+	return
+}
 
-		// If the pattern is not nil, we need to check the content of the file
-		logrus.Debugf("checking content of file %s", path)
-		logrus.Print(string(content))
-		for _, s := range r.Pattern.FindSubmatch(content) {
-			entropy := CalculateShannonEntropy(string(s))
+// findDynamicRuleMatches will search a chunk of a file's content, starting at
+// offset in the file, for all matches to the list of DynamicRule provided
+func findDynamicRuleMatches(path string, content string, offset int64, rules []DynamicRule) (matches []FileMatch) {
+	for _, r := range rules {
+		for _, loc := range r.Pattern.FindAllStringSubmatchIndex(content, -1) {
+			start, end, ok := secretLocation(loc, r.SecretGroup)
+			if !ok {
+				continue
+			}
+			s := content[start:end]
+			entropy := CalculateShannonEntropy(s)
 			if entropy < r.MinEntropy {
 				continue
 			}
 			matches = append(matches, FileMatch{
 				Rule: r,
 				Secret: Secret{
-					Value:   string(s),
+					Value:   s,
 					Entropy: entropy,
 				},
-				Path: path,
+				Path:     path,
+				StartPos: offset + int64(start),
+				EndPos:   offset + int64(end),
+				match:    content[loc[0]:loc[1]],
 			})
 		}
 	}
 	return
+}
 
+// scanChunks will read body in chunks of at most size bytes, calling fn with
+// each chunk and its offset in the body. Each chunk after the first begins with
+// the last overlap bytes of the previous chunk. At most limit bytes are read, unless limit is 0
+func scanChunks(body io.Reader, size, overlap int, limit int64, fn func(chunk string, offset int64)) error {
+	if limit > 0 {
+		body = io.LimitReader(body, limit)
+	}
+
+	var (
+		buf     = make([]byte, size)
+		carried int
+		offset  int64
+	)
+	for {
+		n, err := io.ReadFull(body, buf[carried:])
+		end := carried + n
+		// the carried bytes were already passed with the previous chunk
+		if end > carried {
+			fn(string(buf[:end]), offset)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		carried = overlap
+		if carried > end {
+			carried = end
+		}
+		copy(buf, buf[end-carried:end])
+		offset += int64(end - carried)
+	}
+}
+
+// lineCounter tracks the line and column of offsets in a file searched in
+// chunks, counting the lines of each chunk once it is no longer searched
+type lineCounter struct {
+	// chunk is the chunk being searched
+	chunk string
+	// offset is the offset of chunk in the file
+	offset int64
+	// lines are the amount of newlines in the file before offset
+	lines int
+	// lastNewline is the offset of the last newline before offset, or -1
+	lastNewline int64
+}
+
+// next will move the counter to the next chunk, starting at offset
+func (c *lineCounter) next(chunk string, offset int64) {
+	seen := c.chunk[:offset-c.offset]
+	c.lines += strings.Count(seen, "\n")
+	if i := strings.LastIndexByte(seen, '\n'); i >= 0 {
+		c.lastNewline = c.offset + int64(i)
+	}
+	c.chunk, c.offset = chunk, offset
+}
+
+// position will return the line and column of the offset in the current chunk
+func (c *lineCounter) position(pos int64) (line, column int) {
+	before := c.chunk[:pos-c.offset]
+	line = c.lines + strings.Count(before, "\n") + 1
+	if i := strings.LastIndexByte(before, '\n'); i >= 0 {
+		return line, len(before) - i
+	}
+	return line, int(pos - c.lastNewline)
 }
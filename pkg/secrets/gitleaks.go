@@ -0,0 +1,125 @@
+package secrets
+
+import (
+	"github.com/bthuilot/dockerleaks/internal/config"
+	"github.com/sirupsen/logrus"
+	"regexp"
+)
+
+// ParseGitleaksRules will convert the rules of a gitleaks configuration into
+// StaticRule and DynamicRule. Rules with only a regex are used as both a static
+// and a dynamic rule, while rules with a path are only used as a dynamic rule.
+// All rules that result in error are returned in the third variable
+func ParseGitleaksRules(cfg config.GitleaksConfig) (static []StaticRule, dynamic []DynamicRule, errors []config.GitleaksRule) {
+	for _, r := range cfg.Rules {
+		name := r.Description
+		if name == "" {
+			name = r.ID
+		}
+
+		pattern, err := compileOptional(name, r.Regex)
+		if err != nil {
+			errors = append(errors, r)
+			continue
+		}
+		filePattern, err := compileOptional(name, r.Path)
+		if err != nil {
+			errors = append(errors, r)
+			continue
+		}
+		if pattern == nil && filePattern == nil {
+			logrus.Errorf("gitleaks rule %s has neither a regex nor a path", name)
+			errors = append(errors, r)
+			continue
+		}
+
+		allowlists, err := parseGitleaksAllowlists(name, append([]config.GitleaksAllowlist{r.Allowlist}, r.Allowlists...))
+		if err != nil {
+			errors = append(errors, r)
+			continue
+		}
+
+		if filePattern == nil {
+			static = append(static, StaticRule{
+				Name:        name,
+				Pattern:     pattern,
+				MinEntropy:  r.Entropy,
+				ID:          r.ID,
+				SecretGroup: r.SecretGroup,
+				Keywords:    r.Keywords,
+				Tags:        r.Tags,
+				Allowlists:  allowlists,
+			})
+		}
+		dynamic = append(dynamic, DynamicRule{
+			Name:        name,
+			FilePattern: filePattern,
+			Pattern:     pattern,
+			MinEntropy:  r.Entropy,
+			ID:          r.ID,
+			SecretGroup: r.SecretGroup,
+			Keywords:    r.Keywords,
+			Tags:        r.Tags,
+			Allowlists:  allowlists,
+		})
+	}
+	return
+}
+
+// parseGitleaksAllowlists will convert the allowlists of a gitleaks rule,
+// skipping any allowlist that is empty
+func parseGitleaksAllowlists(name string, allowlists []config.GitleaksAllowlist) ([]Allowlist, error) {
+	var parsed []Allowlist
+	for _, a := range allowlists {
+		var allowlist Allowlist
+		regexes, err := compileAll(name, a.Regexes)
+		if err != nil {
+			return nil, err
+		}
+		if a.RegexTarget == "match" {
+			allowlist.MatchRegexes = regexes
+		} else {
+			allowlist.Regexes = regexes
+		}
+		if allowlist.Paths, err = compileAll(name, a.Paths); err != nil {
+			return nil, err
+		}
+		allowlist.StopWords = a.StopWords
+
+		if len(regexes) == 0 && len(allowlist.Paths) == 0 && len(allowlist.StopWords) == 0 {
+			continue
+		}
+		parsed = append(parsed, allowlist)
+	}
+	return parsed, nil
+}
+
+// compileOptional will compile the pattern, returning nil if the pattern is empty
+func compileOptional(name, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		logrus.Errorf(
+			"unable to parse regular expression %s `%s`: %s",
+			name, pattern, err,
+		)
+	}
+	return regex, err
+}
+
+// compileAll will compile each of the patterns
+func compileAll(name string, patterns []string) ([]*regexp.Regexp, error) {
+	var regexes []*regexp.Regexp
+	for _, p := range patterns {
+		regex, err := compileOptional(name, p)
+		if err != nil {
+			return nil, err
+		}
+		if regex != nil {
+			regexes = append(regexes, regex)
+		}
+	}
+	return regexes, nil
+}
@@ -0,0 +1,219 @@
+package secrets
+
+import (
+	"github.com/bthuilot/dockerleaks/internal/config"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const gitleaksTOML = `
+title = "test config"
+
+[[rules]]
+id = "generic-api-key"
+description = "Generic API Key"
+regex = '''(?i)api_key\s*=\s*['"]([0-9a-z]{16,})['"]'''
+secretGroup = 1
+entropy = 3.5
+keywords = ["api_key"]
+tags = ["generic"]
+
+  [rules.allowlist]
+  regexes = ['''^example''']
+  stopwords = ["dummy"]
+
+[[rules]]
+id = "private-key-file"
+description = "Private Key File"
+path = '''\.pem$'''
+
+[[rules]]
+id = "env-password"
+regex = '''PASSWORD=(\S+)'''
+path = '''\.env$'''
+
+  [[rules.allowlists]]
+  regexTarget = "match"
+  regexes = ['''PASSWORD=changeme''']
+  paths = ['''^test/''']
+
+[[rules]]
+id = "invalid-regex"
+regex = '''(unclosed'''
+
+[[rules]]
+id = "no-pattern"
+description = "No Pattern"
+`
+
+func TestParseGitleaksRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gitleaks.toml")
+	if err := os.WriteFile(path, []byte(gitleaksTOML), 0o600); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	cfg, err := config.LoadGitleaksConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if cfg.Title != "test config" || len(cfg.Rules) != 5 {
+		t.Fatalf("Expected 5 rules titled 'test config', got %d titled '%s'", len(cfg.Rules), cfg.Title)
+	}
+
+	static, dynamic, errors := ParseGitleaksRules(cfg)
+	var failed []string
+	for _, r := range errors {
+		failed = append(failed, r.ID)
+	}
+	if strings.Join(failed, ",") != "invalid-regex,no-pattern" {
+		t.Errorf("Expected rules invalid-regex and no-pattern to fail, got %v", failed)
+	}
+	// only rules without a path are static rules
+	if len(static) != 1 || static[0].ID != "generic-api-key" {
+		t.Fatalf("Expected only the generic-api-key static rule, got %v", static)
+	}
+	if len(dynamic) != 3 {
+		t.Fatalf("Expected 3 dynamic rules, got %d", len(dynamic))
+	}
+
+	var testCases = []struct {
+		id          string
+		name        string
+		secretGroup int
+		entropy     float64
+		pathOnly    bool
+		allowlists  int
+	}{
+		{"generic-api-key", "Generic API Key", 1, 3.5, false, 1},
+		{"private-key-file", "Private Key File", 0, 0, true, 0},
+		// rules without a description are named by their ID
+		{"env-password", "env-password", 0, 0, false, 1},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			r := dynamic[i]
+			if r.ID != tc.id || r.Name != tc.name {
+				t.Errorf("Expected rule %s named '%s', got %s named '%s'", tc.id, tc.name, r.ID, r.Name)
+			}
+			if r.SecretGroup != tc.secretGroup || r.MinEntropy != tc.entropy {
+				t.Errorf("Expected secret group %d and entropy %f, got %d and %f", tc.secretGroup, tc.entropy, r.SecretGroup, r.MinEntropy)
+			}
+			if (r.Pattern == nil) != tc.pathOnly {
+				t.Errorf("Expected path only to be %t, got pattern %v", tc.pathOnly, r.Pattern)
+			}
+			if len(r.Allowlists) != tc.allowlists {
+				t.Errorf("Expected %d allowlists, got %d", tc.allowlists, len(r.Allowlists))
+			}
+		})
+	}
+
+	d := NewDetector(Opts{}, static, dynamic)
+	content := `api_key = "a8Kd93jfLq0Zx7Vb"
+api_key = "example9f8e7d6c5b4a"
+api_key = "dummyA1b2C3d4E5f6"
+`
+	matches, err := d.SearchText(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(matches) != 1 || matches[0].Secret.Value != "a8Kd93jfLq0Zx7Vb" {
+		t.Errorf("Expected only the secret group of the first key, got %v", matches)
+	}
+
+	fileMatches, err := d.SearchFile("app/.env", 35, strings.NewReader("PASSWORD=changeme\nPASSWORD=hunter2\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(fileMatches) != 1 || fileMatches[0].Secret.Value != "PASSWORD=hunter2" {
+		t.Errorf("Expected only the second password, got %v", fileMatches)
+	}
+	if fileMatches, _ = d.SearchFile("test/.env", 16, strings.NewReader("PASSWORD=hunter2")); len(fileMatches) != 0 {
+		t.Errorf("Expected the path to be allowed, got %v", fileMatches)
+	}
+	if fileMatches, _ = d.SearchFile("keys/server.pem", 0, strings.NewReader("")); len(fileMatches) != 1 || fileMatches[0].Line != 0 {
+		t.Errorf("Expected a single path match, got %v", fileMatches)
+	}
+}
+
+func TestParseGitleaksAllowlists(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		allowlists   []config.GitleaksAllowlist
+		parsed       int
+		regexes      int
+		matchRegexes int
+		err          bool
+	}{
+		{"empty", []config.GitleaksAllowlist{{}}, 0, 0, 0, false},
+		{"secret target", []config.GitleaksAllowlist{{Regexes: []string{`^a`, `^b`}}}, 1, 2, 0, false},
+		{"match target", []config.GitleaksAllowlist{{Regexes: []string{`^a`}, RegexTarget: "match"}}, 1, 0, 1, false},
+		{"stopwords only", []config.GitleaksAllowlist{{}, {StopWords: []string{"example"}}}, 1, 0, 0, false},
+		{"invalid regex", []config.GitleaksAllowlist{{Regexes: []string{`(`}}}, 0, 0, 0, true},
+		{"invalid path", []config.GitleaksAllowlist{{Paths: []string{`(`}}}, 0, 0, 0, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, err := parseGitleaksAllowlists(tc.name, tc.allowlists)
+			if (err != nil) != tc.err {
+				t.Fatalf("Expected error to be %t, got %v", tc.err, err)
+			}
+			if len(parsed) != tc.parsed {
+				t.Fatalf("Expected %d allowlists, got %d", tc.parsed, len(parsed))
+			}
+			if tc.parsed == 0 {
+				return
+			}
+			if len(parsed[0].Regexes) != tc.regexes || len(parsed[0].MatchRegexes) != tc.matchRegexes {
+				t.Errorf("Expected %d secret and %d match regexes, got %d and %d",
+					tc.regexes, tc.matchRegexes, len(parsed[0].Regexes), len(parsed[0].MatchRegexes))
+			}
+		})
+	}
+}
+
+func TestLoadGitleaksConfigInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gitleaks.toml")
+	if _, err := config.LoadGitleaksConfig(path); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+	if err := os.WriteFile(path, []byte("[[rules]\n"), 0o600); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if _, err := config.LoadGitleaksConfig(path); err == nil {
+		t.Errorf("Expected an error for invalid TOML")
+	}
+}
+
+func TestPathRuleAllowlists(t *testing.T) {
+	// secret regexes and stopwords that match any value must not allow a match of the path only
+	allowlists, err := parseGitleaksAllowlists("Private Key File", []config.GitleaksAllowlist{
+		{Regexes: []string{`.*`}},
+		{Regexes: []string{`^$`}, RegexTarget: "match"},
+		{StopWords: []string{""}},
+		{Paths: []string{`^test/`}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	d := NewDetector(Opts{}, nil, []DynamicRule{{
+		Name:        "Private Key File",
+		FilePattern: regexp.MustCompile(`\.pem$`),
+		Allowlists:  allowlists,
+	}})
+
+	matches, err := d.SearchFile("keys/server.pem", 0, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(matches) != 1 {
+		t.Errorf("Expected the path to match, got %v", matches)
+	}
+	if matches, _ = d.SearchFile("test/server.pem", 0, strings.NewReader("")); len(matches) != 0 {
+		t.Errorf("Expected the path to be allowed, got %v", matches)
+	}
+	if suppressed := d.Suppressed(); suppressed != 1 {
+		t.Errorf("Expected 1 suppressed match, got %d", suppressed)
+	}
+}
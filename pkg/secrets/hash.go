@@ -0,0 +1,59 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Hash will return a hash of the rules and options of the detector,
+// which changes whenever the matches found by the detector could change
+func (d detector) Hash() string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "opts %d %d %d %d %q\n",
+		d.opts.ChunkSize, d.opts.ChunkOverlap, d.opts.MaxFileSize, d.opts.ContextLines, d.opts.OversizedFiles,
+	)
+	hashAllowlists(h, d.opts.Allowlists)
+	for _, r := range d.staticRules {
+		_, _ = fmt.Fprintf(h, "static %q %q %v %q %d %q %q %q\n",
+			r.Name, regexString(r.Pattern), r.MinEntropy, r.ID, r.SecretGroup, r.Keywords, r.Tags, r.Severity,
+		)
+		hashAllowlists(h, r.Allowlists)
+	}
+	for _, r := range d.dynamicRules {
+		_, _ = fmt.Fprintf(h, "dynamic %q %q %q %v %q %d %q %q %q\n",
+			r.Name, regexString(r.FilePattern), regexString(r.Pattern), r.MinEntropy, r.ID, r.SecretGroup, r.Keywords, r.Tags, r.Severity,
+		)
+		hashAllowlists(h, r.Allowlists)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashAllowlists will write the conditions of each allowlist to the hash
+func hashAllowlists(w io.Writer, allowlists []Allowlist) {
+	for _, a := range allowlists {
+		_, _ = fmt.Fprintf(w, "allowlist %q %q %q %q %q %q\n",
+			regexStrings(a.Regexes), regexStrings(a.MatchRegexes), regexStrings(a.Paths),
+			regexStrings(a.Variables), a.Hashes, a.StopWords,
+		)
+	}
+}
+
+// regexString will return the pattern of the regular expression, or an empty string if it is nil
+func regexString(r *regexp.Regexp) string {
+	if r == nil {
+		return ""
+	}
+	return r.String()
+}
+
+// regexStrings will return the pattern of each regular expression
+func regexStrings(regexes []*regexp.Regexp) []string {
+	patterns := make([]string, 0, len(regexes))
+	for _, r := range regexes {
+		patterns = append(patterns, regexString(r))
+	}
+	return patterns
+}
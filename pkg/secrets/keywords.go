@@ -0,0 +1,164 @@
+package secrets
+
+// keywordMatcher is an [Aho-Corasick automaton] for finding which of a set
+// of keywords are contained in a text in a single pass over the text.
+// Keywords are matched ignoring the case of ASCII letters.
+//
+// [Aho-Corasick automaton]: https://en.wikipedia.org/wiki/Aho%E2%80%93Corasick_algorithm
+type keywordMatcher struct {
+	// transitions is the state reached from each state by each byte,
+	// with the failure transitions already resolved
+	transitions [][256]int32
+	// outputs are the keywords ending at each state,
+	// including the keywords of its failure state
+	outputs [][]int
+}
+
+// newKeywordMatcher will build a keywordMatcher of the keywords,
+// where each keyword is identified by its index
+func newKeywordMatcher(keywords []string) *keywordMatcher {
+	m := &keywordMatcher{
+		transitions: make([][256]int32, 1),
+		outputs:     make([][]int, 1),
+	}
+
+	// build the trie of the keywords, where -1 is a missing transition
+	for i := range m.transitions[0] {
+		m.transitions[0][i] = -1
+	}
+	for id, k := range keywords {
+		state := int32(0)
+		for i := 0; i < len(k); i++ {
+			c := lowerASCII(k[i])
+			if m.transitions[state][c] < 0 {
+				m.transitions = append(m.transitions, [256]int32{})
+				m.outputs = append(m.outputs, nil)
+				next := int32(len(m.transitions) - 1)
+				for j := range m.transitions[next] {
+					m.transitions[next][j] = -1
+				}
+				m.transitions[state][c] = next
+			}
+			state = m.transitions[state][c]
+		}
+		m.outputs[state] = append(m.outputs[state], id)
+	}
+
+	// resolve the failure transitions breadth first, so that the failure
+	// state of each state is resolved before the state itself
+	fail := make([]int32, len(m.transitions))
+	var queue []int32
+	for c := range m.transitions[0] {
+		if next := m.transitions[0][c]; next < 0 {
+			m.transitions[0][c] = 0
+		} else {
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.outputs[state] = append(m.outputs[state], m.outputs[fail[state]]...)
+		for c := range m.transitions[state] {
+			next := m.transitions[state][c]
+			if next < 0 {
+				m.transitions[state][c] = m.transitions[fail[state]][c]
+				continue
+			}
+			fail[next] = m.transitions[fail[state]][c]
+			queue = append(queue, next)
+		}
+	}
+	return m
+}
+
+// match will set found to true for the index of each keyword contained in the text
+func (m *keywordMatcher) match(text string, found []bool) {
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		state = m.transitions[state][lowerASCII(text[i])]
+		for _, id := range m.outputs[state] {
+			found[id] = true
+		}
+	}
+}
+
+// lowerASCII will return the lower case of an ASCII letter,
+// or the byte itself if it is not an upper case ASCII letter
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// keywordFilter selects the rules whose keywords are contained in a text,
+// so that the pattern of rules that cannot match is never evaluated
+type keywordFilter struct {
+	// matcher finds the keywords of all rules
+	matcher *keywordMatcher
+	// keywordRules are the indexes of the rules of each keyword
+	keywordRules [][]int
+	// unfiltered are the indexes of the rules without any keywords
+	unfiltered []int
+	// count is the amount of rules
+	count int
+}
+
+// newKeywordFilter will build a keywordFilter of rules with the given keywords,
+// where each rule is identified by its index
+func newKeywordFilter(ruleKeywords [][]string) keywordFilter {
+	var (
+		filter   = keywordFilter{count: len(ruleKeywords)}
+		keywords []string
+		ids      = make(map[string]int)
+	)
+	for r, rk := range ruleKeywords {
+		if len(rk) == 0 {
+			filter.unfiltered = append(filter.unfiltered, r)
+			continue
+		}
+		for _, k := range rk {
+			id, ok := ids[k]
+			if !ok {
+				id = len(keywords)
+				ids[k] = id
+				keywords = append(keywords, k)
+				filter.keywordRules = append(filter.keywordRules, nil)
+			}
+			filter.keywordRules[id] = append(filter.keywordRules[id], r)
+		}
+	}
+	filter.matcher = newKeywordMatcher(keywords)
+	return filter
+}
+
+// rules will return the indexes, in order, of the rules that should be checked against the text
+func (f keywordFilter) rules(text string) []int {
+	if len(f.keywordRules) == 0 {
+		return f.unfiltered
+	}
+	found := make([]bool, len(f.keywordRules))
+	f.matcher.match(text, found)
+
+	selected := make([]bool, f.count)
+	for _, r := range f.unfiltered {
+		selected[r] = true
+	}
+	for id, ok := range found {
+		if !ok {
+			continue
+		}
+		for _, r := range f.keywordRules[id] {
+			selected[r] = true
+		}
+	}
+
+	var indexes []int
+	for r, ok := range selected {
+		if ok {
+			indexes = append(indexes, r)
+		}
+	}
+	return indexes
+}
@@ -1,6 +1,8 @@
 package secrets
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/bthuilot/dockerleaks/internal/config"
 	"github.com/sirupsen/logrus"
@@ -11,6 +13,10 @@ import (
 // Rule represents a rule for matching secret strings
 type Rule interface {
 	String() string
+	// RuleID will return an identifier of the rule that is stable between scans
+	RuleID() string
+	// RuleSeverity will return how severe the leak of a secret matched by the rule is
+	RuleSeverity() Severity
 }
 
 // StaticRule represents a pattern and entropy rule for matching
@@ -23,6 +29,35 @@ type StaticRule struct {
 	Pattern *regexp.Regexp `json:"pattern"`
 	// MinEntropy is the minimum entropy the string must be
 	MinEntropy float64 `json:"min_entropy,omitempty"`
+	// ID is the unique identifier of the rule, if one was given
+	ID string `json:"id,omitempty"`
+	// SecretGroup is the capture group of Pattern containing the secret.
+	// a value of 0 means that the full match is the secret
+	SecretGroup int `json:"secret_group,omitempty"`
+	// Keywords are strings, one of which must be present (ignoring case)
+	// in the text for the rule to be checked
+	Keywords []string `json:"keywords,omitempty"`
+	// Tags are arbitrary labels for the rule
+	Tags []string `json:"tags,omitempty"`
+	// Severity is how severe the leak of a secret matched by the rule is,
+	// the DefaultSeverity if empty
+	Severity Severity `json:"severity,omitempty"`
+	// Allowlists are conditions under which a match of the rule is not a secret
+	Allowlists []Allowlist `json:"allowlists,omitempty"`
+}
+
+// RuleID will return the ID of the rule, or an identifier derived
+// from the name and pattern of the rule if it has no ID
+func (r StaticRule) RuleID() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return derivedRuleID(r.Name, r.String())
+}
+
+// RuleSeverity will return the severity of the rule, or the DefaultSeverity if it has none
+func (r StaticRule) RuleSeverity() Severity {
+	return ruleSeverity(r.Severity)
 }
 
 func (r StaticRule) String() string {
@@ -54,6 +89,35 @@ type DynamicRule struct {
 	// This will only be used if Pattern is not nil
 	// a value of 0 means that the entropy will not be checked
 	MinEntropy float64 `json:"min_entropy,omitempty"`
+	// ID is the unique identifier of the rule, if one was given
+	ID string `json:"id,omitempty"`
+	// SecretGroup is the capture group of Pattern containing the secret.
+	// a value of 0 means that the full match is the secret
+	SecretGroup int `json:"secret_group,omitempty"`
+	// Keywords are strings, one of which must be present (ignoring case)
+	// in the text for the rule to be checked
+	Keywords []string `json:"keywords,omitempty"`
+	// Tags are arbitrary labels for the rule
+	Tags []string `json:"tags,omitempty"`
+	// Severity is how severe the leak of a secret matched by the rule is,
+	// the DefaultSeverity if empty
+	Severity Severity `json:"severity,omitempty"`
+	// Allowlists are conditions under which a match of the rule is not a secret
+	Allowlists []Allowlist `json:"allowlists,omitempty"`
+}
+
+// RuleID will return the ID of the rule, or an identifier derived
+// from the name, file pattern and pattern of the rule if it has no ID
+func (r DynamicRule) RuleID() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return derivedRuleID(r.Name, r.String())
+}
+
+// RuleSeverity will return the severity of the rule, or the DefaultSeverity if it has none
+func (r DynamicRule) RuleSeverity() Severity {
+	return ruleSeverity(r.Severity)
 }
 
 func (r DynamicRule) String() string {
@@ -73,6 +137,17 @@ func (r DynamicRule) String() string {
 	return fmt.Sprintf("'%s'", r.Name)
 }
 
+// derivedRuleID will return an identifier of a rule without an ID, from its name and
+// description. Rules commonly share a name, so a hash of the description is included
+func derivedRuleID(name, description string) string {
+	slug := strings.Trim(nonIDCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	sum := sha256.Sum256([]byte(description))
+	return fmt.Sprintf("%s-%s", slug, hex.EncodeToString(sum[:4]))
+}
+
+// nonIDCharacters are the characters of a rule name that are replaced in its derived ID
+var nonIDCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
 // DefaultStaticRules is the default list of rules
 // this list contains rules to match a common
 // set of secrets
@@ -85,8 +160,9 @@ var DefaultStaticRules = []StaticRule{
 		Pattern: regexp.MustCompile(`/(^|[^@\w])@(\w{1,15})\b/`),
 		Name:    "Twitter",
 	}, {
-		Pattern: regexp.MustCompile(`EAACEdEose0cBA[0-9A-Za-z]+`),
-		Name:    "Facebook",
+		Pattern:  regexp.MustCompile(`EAACEdEose0cBA[0-9A-Za-z]+`),
+		Name:     "Facebook",
+		Keywords: []string{"eaacedeose0cba"},
 	}, {
 		Pattern: regexp.MustCompile(`[A-Za-z0-9]{125}`),
 		Name:    "Facebook",
@@ -94,8 +170,10 @@ var DefaultStaticRules = []StaticRule{
 		Pattern: regexp.MustCompile(`[0-9a-fA-F]{7}\.[0-9a-fA-F]{32}`),
 		Name:    "Instagram",
 	}, {
-		Pattern: regexp.MustCompile(`AIza[0-9A-Za-z-_]{35}`),
-		Name:    "Google",
+		Pattern:  regexp.MustCompile(`AIza[0-9A-Za-z-_]{35}`),
+		Name:     "Google",
+		Keywords: []string{"aiza"},
+		Severity: High,
 	}, {
 		Pattern: regexp.MustCompile(`[0-9a-zA-Z\-_]{24}`),
 		Name:    "Google",
@@ -106,83 +184,121 @@ var DefaultStaticRules = []StaticRule{
 		Pattern: regexp.MustCompile(`1/[0-9A-Za-z\-_]{43}|1/[0-9A-Za-z\-_]{64}`),
 		Name:    "Google",
 	}, {
-		Pattern: regexp.MustCompile(`ya29\.[0-9A-Za-z\-_]+`),
-		Name:    "Google",
-	}, {
-		Pattern: regexp.MustCompile(`^ghp_[a-zA-Z0-9]{36}$`),
-		Name:    "GitHub",
-	}, {
-		Pattern: regexp.MustCompile(`^github_pat_[a-zA-Z0-9]{22}_[a-zA-Z0-9]{59}$`),
-		Name:    "GitHub",
-	}, {
-		Pattern: regexp.MustCompile(`^gho_[a-zA-Z0-9]{36}$`),
-		Name:    "GitHub",
-	}, {
-		Pattern: regexp.MustCompile(`^ghu_[a-zA-Z0-9]{36}$`),
-		Name:    "GitHub",
-	}, {
-		Pattern: regexp.MustCompile(`^ghs_[a-zA-Z0-9]{36}$`),
-		Name:    "GitHub",
-	}, {
-		Pattern: regexp.MustCompile(`^ghr_[a-zA-Z0-9]{36}$`),
-		Name:    "GitHub",
-	}, {
-		Pattern: regexp.MustCompile(`([s,p]k.eyJ1Ijoi[\w\.-]+)`),
-		Name:    "Mapbox",
-	}, {
-		Pattern: regexp.MustCompile(`([s,p]k.eyJ1Ijoi[\w\.-]+)`),
-		Name:    "Mapbox",
+		Pattern:  regexp.MustCompile(`ya29\.[0-9A-Za-z\-_]+`),
+		Name:     "Google",
+		Keywords: []string{"ya29."},
+		Severity: High,
+	}, {
+		Pattern:  regexp.MustCompile(`^ghp_[a-zA-Z0-9]{36}$`),
+		Name:     "GitHub",
+		Keywords: []string{"ghp_"},
+		Severity: Critical,
+	}, {
+		Pattern:  regexp.MustCompile(`^github_pat_[a-zA-Z0-9]{22}_[a-zA-Z0-9]{59}$`),
+		Name:     "GitHub",
+		Keywords: []string{"github_pat_"},
+		Severity: Critical,
+	}, {
+		Pattern:  regexp.MustCompile(`^gho_[a-zA-Z0-9]{36}$`),
+		Name:     "GitHub",
+		Keywords: []string{"gho_"},
+		Severity: Critical,
+	}, {
+		Pattern:  regexp.MustCompile(`^ghu_[a-zA-Z0-9]{36}$`),
+		Name:     "GitHub",
+		Keywords: []string{"ghu_"},
+		Severity: Critical,
+	}, {
+		Pattern:  regexp.MustCompile(`^ghs_[a-zA-Z0-9]{36}$`),
+		Name:     "GitHub",
+		Keywords: []string{"ghs_"},
+		Severity: Critical,
+	}, {
+		Pattern:  regexp.MustCompile(`^ghr_[a-zA-Z0-9]{36}$`),
+		Name:     "GitHub",
+		Keywords: []string{"ghr_"},
+		Severity: Critical,
+	}, {
+		Pattern:  regexp.MustCompile(`([s,p]k.eyJ1Ijoi[\w\.-]+)`),
+		Name:     "Mapbox",
+		Keywords: []string{"eyj1ijoi"},
+	}, {
+		Pattern:  regexp.MustCompile(`([s,p]k.eyJ1Ijoi[\w\.-]+)`),
+		Name:     "Mapbox",
+		Keywords: []string{"eyj1ijoi"},
 	}, {
 		Pattern: regexp.MustCompile(`R_[0-9a-f]{32}`),
 		Name:    "Foursquare",
 	}, {
-		Pattern: regexp.MustCompile(`sk_live_[0-9a-z]{32}`),
-		Name:    "Picatic",
+		Pattern:  regexp.MustCompile(`sk_live_[0-9a-z]{32}`),
+		Name:     "Picatic",
+		Keywords: []string{"sk_live_"},
+		Severity: High,
 	}, {
-		Pattern: regexp.MustCompile(`sk_live_[0-9a-zA-Z]{24}`),
-		Name:    "Stripe",
+		Pattern:  regexp.MustCompile(`sk_live_[0-9a-zA-Z]{24}`),
+		Name:     "Stripe",
+		Keywords: []string{"sk_live_"},
+		Severity: High,
 	}, {
-		Pattern: regexp.MustCompile(`sk_live_[0-9a-zA-Z]{24}`),
-		Name:    "Stripe",
+		Pattern:  regexp.MustCompile(`sk_live_[0-9a-zA-Z]{24}`),
+		Name:     "Stripe",
+		Keywords: []string{"sk_live_"},
+		Severity: High,
 	}, {
-		Pattern: regexp.MustCompile(`sqOatp-[0-9A-Za-z\-_]{22}`),
-		Name:    "Square",
+		Pattern:  regexp.MustCompile(`sqOatp-[0-9A-Za-z\-_]{22}`),
+		Name:     "Square",
+		Keywords: []string{"sqoatp-"},
+		Severity: High,
 	}, {
-		Pattern: regexp.MustCompile(`q0csp-[0-9A-Za-z\-_]{43}`),
-		Name:    "Square",
+		Pattern:  regexp.MustCompile(`q0csp-[0-9A-Za-z\-_]{43}`),
+		Name:     "Square",
+		Keywords: []string{"q0csp-"},
+		Severity: High,
 	}, {
-		Pattern: regexp.MustCompile(`access_token\,production\$[0-9a-z]{161}[0-9a,]{32}`),
-		Name:    "Paypal / Braintree",
+		Pattern:  regexp.MustCompile(`access_token\,production\$[0-9a-z]{161}[0-9a,]{32}`),
+		Name:     "Paypal / Braintree",
+		Keywords: []string{"access_token,production$"},
+		Severity: Critical,
 	}, {
-		Pattern: regexp.MustCompile(`amzn\.mws\.[0-9a-f]{8}-[0-9a-f]{4}-10-9a-f1{4}-[0-9a,]{4}-[0-9a-f]{12}`),
-		Name:    "Amazon Marketing Services",
+		Pattern:  regexp.MustCompile(`amzn\.mws\.[0-9a-f]{8}-[0-9a-f]{4}-10-9a-f1{4}-[0-9a,]{4}-[0-9a-f]{12}`),
+		Name:     "Amazon Marketing Services",
+		Keywords: []string{"amzn.mws."},
 	}, {
 		Pattern: regexp.MustCompile(`55[0-9a-fA-F]{32}`),
 		Name:    "Twilio",
 	}, {
-		Pattern: regexp.MustCompile(`key-[0-9a-zA-Z]{32}`),
-		Name:    "MailGun",
+		Pattern:  regexp.MustCompile(`key-[0-9a-zA-Z]{32}`),
+		Name:     "MailGun",
+		Keywords: []string{"key-"},
 	}, {
 		Pattern: regexp.MustCompile(`[ 0-9a-f ]{ 32 }-us[0-9]{1,2}`),
 		Name:    "MailChimp",
 	}, {
-		Pattern: regexp.MustCompile(`xoxb-[0-9]{11}-[0-9]{11}-[0-9a-zA-Z]{24}`),
-		Name:    "Slack",
+		Pattern:  regexp.MustCompile(`xoxb-[0-9]{11}-[0-9]{11}-[0-9a-zA-Z]{24}`),
+		Name:     "Slack",
+		Keywords: []string{"xoxb-"},
+		Severity: High,
 	}, {
-		Pattern: regexp.MustCompile(`xoxp-[0-9]{11}-[0-9]{11}-[0-9a-zA-Z]{24}`),
-		Name:    "Slack",
+		Pattern:  regexp.MustCompile(`xoxp-[0-9]{11}-[0-9]{11}-[0-9a-zA-Z]{24}`),
+		Name:     "Slack",
+		Keywords: []string{"xoxp-"},
+		Severity: High,
 	}, {
-		Pattern: regexp.MustCompile(`xoxe.xoxp-1-[0-9a-zA-Z]{166}`),
-		Name:    "Slack",
+		Pattern:  regexp.MustCompile(`xoxe.xoxp-1-[0-9a-zA-Z]{166}`),
+		Name:     "Slack",
+		Keywords: []string{"xoxe"},
 	}, {
-		Pattern: regexp.MustCompile(`xoxe-1-[0-9a-zA-Z]{147}`),
-		Name:    "Slack",
+		Pattern:  regexp.MustCompile(`xoxe-1-[0-9a-zA-Z]{147}`),
+		Name:     "Slack",
+		Keywords: []string{"xoxe-1-"},
 	}, {
 		Pattern: regexp.MustCompile(`T[a-zA-Z0-9_]{8}/B[a-zA-Z0-9_]{8}/[a-zA-Z0-9_]{24}`),
 		Name:    "Slack",
 	}, {
-		Pattern: regexp.MustCompile(`A[KS]IA[0-9A-Z]{16}`),
-		Name:    "Amazon Web Services",
+		Pattern:  regexp.MustCompile(`A[KS]IA[0-9A-Z]{16}`),
+		Name:     "Amazon Web Services",
+		Keywords: []string{"akia", "asia"},
+		Severity: Critical,
 	}, {
 		Pattern: regexp.MustCompile(`[0-9a-zA-Z/+]{40}`),
 		Name:    "Amazon Web Services",
@@ -205,14 +321,17 @@ var DefaultDynamicRules = []DynamicRule{
 	{
 		FilePattern: regexp.MustCompile(`^(.*/)*[-\w._]*\.env(\.[-\w._]*)?$`),
 		Name:        ".env file",
+		Severity:    High,
 	},
 	{
 		Name:        "Terraform state file",
 		FilePattern: regexp.MustCompile(`^(.*/)*terraform.tfstate$`),
+		Severity:    Critical,
 	},
 	{
 		Name:        "AWS credentials file",
 		FilePattern: regexp.MustCompile(`^(.*/)*\.aws/credentials$`),
+		Severity:    Critical,
 	},
 }
 
@@ -227,11 +346,28 @@ func ParseStaticRules(userRules []config.UserStaticRule) (rules []StaticRule, er
 				r.Name, r.Pattern, err,
 			)
 			errors = append(errors, r)
+			continue
+		}
+		severity, err := ParseSeverity(r.Severity)
+		if err != nil {
+			logrus.Errorf("unable to parse severity of rule %s: %s", r.Name, err)
+			errors = append(errors, r)
+			continue
+		}
+		allowlists, err := ParseAllowlists(r.Allowlists)
+		if err != nil {
+			logrus.Errorf("unable to parse allowlists of rule %s: %s", r.Name, err)
+			errors = append(errors, r)
+			continue
 		}
 		rules = append(rules, StaticRule{
-			Pattern:    regex,
-			Name:       r.Name,
-			MinEntropy: r.MinEntropy,
+			Pattern:     regex,
+			Name:        r.Name,
+			MinEntropy:  r.MinEntropy,
+			SecretGroup: r.SecretGroup,
+			Keywords:    r.Keywords,
+			Severity:    severity,
+			Allowlists:  allowlists,
 		})
 	}
 	return
@@ -243,6 +379,18 @@ func ParseDynamicRules(userRules []config.UserDynamicRule) (rules []DynamicRule,
 	for _, r := range userRules {
 		var rule DynamicRule
 		rule.Name = r.Name
+		severity, err := ParseSeverity(r.Severity)
+		if err != nil {
+			logrus.Errorf("unable to parse severity of rule %s: %s", r.Name, err)
+			errors = append(errors, r)
+			continue
+		}
+		rule.Severity = severity
+		if rule.Allowlists, err = ParseAllowlists(r.Allowlists); err != nil {
+			logrus.Errorf("unable to parse allowlists of rule %s: %s", r.Name, err)
+			errors = append(errors, r)
+			continue
+		}
 		if r.FilePattern != "" {
 			regex, err := regexp.Compile(r.FilePattern)
 			if err != nil {
@@ -267,6 +415,8 @@ func ParseDynamicRules(userRules []config.UserDynamicRule) (rules []DynamicRule,
 			}
 			rule.Pattern = regex
 			rule.MinEntropy = r.MinEntropy
+			rule.SecretGroup = r.SecretGroup
+			rule.Keywords = r.Keywords
 		}
 		rules = append(rules, rule)
 	}
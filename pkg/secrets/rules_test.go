@@ -0,0 +1,31 @@
+package secrets
+
+import (
+	"github.com/bthuilot/dockerleaks/internal/config"
+	"testing"
+)
+
+func TestParseStaticRules(t *testing.T) {
+	rules, errors := ParseStaticRules([]config.UserStaticRule{
+		{Name: "valid", Pattern: `key-[0-9]+`, Severity: "high"},
+		{Name: "invalid severity", Pattern: `key-[0-9]+`, Severity: "severe"},
+		{Name: "invalid regex and severity", Pattern: `(`, Severity: "severe"},
+		{Name: "invalid allowlist", Pattern: `key-[0-9]+`, Allowlists: []config.UserAllowlist{{Paths: []string{`(`}}}},
+	})
+	if len(rules) != 1 || rules[0].Name != "valid" || rules[0].Severity != High {
+		t.Errorf("Expected only the valid rule, got %v", rules)
+	}
+	var failed []string
+	for _, r := range errors {
+		failed = append(failed, r.Name)
+	}
+	expected := []string{"invalid severity", "invalid regex and severity", "invalid allowlist"}
+	if len(failed) != len(expected) {
+		t.Fatalf("Expected each invalid rule to be reported once, got %v", failed)
+	}
+	for i := range expected {
+		if failed[i] != expected[i] {
+			t.Errorf("Expected invalid rules %v, got %v", expected, failed)
+		}
+	}
+}
@@ -12,11 +12,16 @@ type Secret struct {
 	Entropy float64 `json:"entropy"`
 }
 
+// Unmasked will return true if secrets are shown with their raw values instead of masked
+func Unmasked() bool {
+	return viper.GetBool(config.ViperUnmaskKey)
+}
+
 func (s Secret) String() string {
 	if s.Value == "" {
 		return ""
 	}
-	if viper.GetBool(config.ViperUnmaskKey) {
+	if Unmasked() {
 		return s.Value
 	}
 	if len(s.Value) < 4 {
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is how severe the leak of a secret matched by a rule is
+type Severity string
+
+const (
+	Low      Severity = "low"
+	Medium   Severity = "medium"
+	High     Severity = "high"
+	Critical Severity = "critical"
+)
+
+// DefaultSeverity is the severity of rules without a severity
+const DefaultSeverity = Medium
+
+// Severities are all severities, from least to most severe
+var Severities = []Severity{Low, Medium, High, Critical}
+
+// ParseSeverity will parse the name of a severity, ignoring case.
+// An empty name is the DefaultSeverity
+func ParseSeverity(name string) (Severity, error) {
+	if name == "" {
+		return DefaultSeverity, nil
+	}
+	s := Severity(strings.ToLower(name))
+	if s.rank() < 0 {
+		return "", fmt.Errorf("invalid severity '%s', must be one of: low, medium, high, critical", name)
+	}
+	return s, nil
+}
+
+// AtLeast will return true if the severity is at least as severe as min
+func (s Severity) AtLeast(min Severity) bool {
+	return s.rank() >= min.rank()
+}
+
+// rank will return the index of the severity in Severities, or -1 if it is not a severity
+func (s Severity) rank() int {
+	for i, severity := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// ruleSeverity will return the severity of a rule, or the DefaultSeverity if it has none
+func ruleSeverity(s Severity) Severity {
+	if s == "" {
+		return DefaultSeverity
+	}
+	return s
+}
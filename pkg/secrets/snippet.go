@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"strings"
+)
+
+// maxSnippetLineLength is the maximum length of a line of a Snippet,
+// so that minified files do not produce huge snippets
+const maxSnippetLineLength = 200
+
+// Snippet is the lines of a file surrounding a secret
+type Snippet struct {
+	// StartLine is the line number of the first of Lines, starting from 1
+	StartLine int `json:"start_line"`
+	// Lines are the lines of the file surrounding the secret
+	Lines []string `json:"lines"`
+}
+
+// Mask will return a copy of the snippet with each of the secrets
+// replaced by their masked value (see [Secret.String])
+func (s Snippet) Mask(secrets ...Secret) Snippet {
+	masked := Snippet{StartLine: s.StartLine, Lines: make([]string, len(s.Lines))}
+	copy(masked.Lines, s.Lines)
+	for _, secret := range secrets {
+		if secret.Value == "" {
+			continue
+		}
+		for i := range masked.Lines {
+			masked.Lines[i] = strings.ReplaceAll(masked.Lines[i], secret.Value, secret.String())
+		}
+	}
+	return masked
+}
+
+// extractSnippet will return the n lines before and after the line of the
+// secret located at start to end in the chunk, where line is the line of start.
+// Lines outside the chunk are not included.
+func extractSnippet(chunk string, start, end, line, n int) *Snippet {
+	if n < 0 {
+		return nil
+	}
+
+	// find the start of the n-th line before the secret
+	lineStart := strings.LastIndexByte(chunk[:start], '\n') + 1
+	from, startLine := lineStart, line
+	for i := 0; i < n && from > 0; i++ {
+		from = strings.LastIndexByte(chunk[:from-1], '\n') + 1
+		startLine--
+	}
+
+	// find the end of the n-th line after the secret
+	to := end
+	for i := 0; i <= n; i++ {
+		j := strings.IndexByte(chunk[to:], '\n')
+		if j < 0 {
+			to = len(chunk)
+			break
+		}
+		if i == n {
+			to += j
+			break
+		}
+		to += j + 1
+	}
+	if to > from && chunk[to-1] == '\n' {
+		to--
+	}
+
+	lines := strings.Split(chunk[from:to], "\n")
+	secretLine := line - startLine
+	for i, l := range lines {
+		if len(l) <= maxSnippetLineLength {
+			continue
+		}
+		if i != secretLine {
+			lines[i] = l[:maxSnippetLineLength] + "..."
+			continue
+		}
+		// keep the secret, and as much of the line around it as fits
+		lines[i] = truncateAround(l, start-lineStart, end-lineStart)
+	}
+	return &Snippet{StartLine: startLine, Lines: lines}
+}
+
+// truncateAround will truncate the line to the text surrounding start to end
+func truncateAround(line string, start, end int) string {
+	margin := (maxSnippetLineLength - (end - start)) / 2
+	if margin < 0 {
+		margin = 0
+	}
+	from, to := start-margin, end+margin
+	prefix, suffix := "...", "..."
+	if from <= 0 {
+		from, prefix = 0, ""
+	}
+	if to >= len(line) {
+		to, suffix = len(line), ""
+	}
+	return prefix + line[from:to] + suffix
+}
@@ -0,0 +1,57 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractSnippet(t *testing.T) {
+	chunk := "one\ntwo\nkey=SECRET\nfour\nfive\n"
+	start := strings.Index(chunk, "SECRET")
+	end := start + len("SECRET")
+
+	var testCases = []struct {
+		n         int
+		startLine int
+		lines     []string
+	}{
+		{0, 3, []string{"key=SECRET"}},
+		{1, 2, []string{"two", "key=SECRET", "four"}},
+		{5, 1, []string{"one", "two", "key=SECRET", "four", "five"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(tc.n), func(t *testing.T) {
+			s := extractSnippet(chunk, start, end, 3, tc.n)
+			if s.StartLine != tc.startLine || fmt.Sprint(s.Lines) != fmt.Sprint(tc.lines) {
+				t.Errorf("Expected lines %q from %d, got %q from %d", tc.lines, tc.startLine, s.Lines, s.StartLine)
+			}
+		})
+	}
+
+	if s := extractSnippet(chunk, start, end, 3, -1); s != nil {
+		t.Errorf("Expected no snippet, got %v", s)
+	}
+}
+
+func TestExtractSnippetLongLine(t *testing.T) {
+	line := strings.Repeat("a", 1000) + "SECRET" + strings.Repeat("b", 1000)
+	start := strings.Index(line, "SECRET")
+
+	s := extractSnippet(line, start, start+len("SECRET"), 1, 0)
+	if len(s.Lines) != 1 || !strings.Contains(s.Lines[0], "SECRET") || len(s.Lines[0]) > maxSnippetLineLength+6 {
+		t.Errorf("Expected truncated line containing the secret, got %q", s.Lines)
+	}
+}
+
+func TestSnippetMask(t *testing.T) {
+	s := Snippet{StartLine: 1, Lines: []string{"a=SECRETVALUE", "b=OTHERVALUE"}}
+	masked := s.Mask(Secret{Value: "SECRETVALUE"}, Secret{Value: "OTHERVALUE"})
+	if fmt.Sprint(masked.Lines) != fmt.Sprint([]string{"a=SEC********LUE", "b=OTH********LUE"}) {
+		t.Errorf("Expected masked lines, got %q", masked.Lines)
+	}
+	if s.Lines[0] != "a=SECRETVALUE" {
+		t.Errorf("Expected original snippet to be unchanged, got %q", s.Lines)
+	}
+}
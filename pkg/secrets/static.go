@@ -8,17 +8,24 @@ type TextMatch struct {
 	Secret Secret
 	// FullText is the full text that was searches
 	FullText string
-	// StartPos is the starting position of the match
+	// StartPos is the starting byte offset of the secret in FullText
 	StartPos int
-	// EndPos is the ending position of the match
+	// EndPos is the ending byte offset of the secret in FullText
 	EndPos int
+	// match is the full match of the rule, which contains the secret
+	match string
 }
 
-// findRuleMatches will search a string's content for any matches to
+// findRuleMatches will search a string's content for all matches to
 // the list of SecretStringRules provided
 func findStaticRuleMatches(content string, rules []StaticRule) (matches []TextMatch, err error) {
 	for _, r := range rules {
-		for _, s := range r.Pattern.FindStringSubmatch(content) {
+		for _, loc := range r.Pattern.FindAllStringSubmatchIndex(content, -1) {
+			start, end, ok := secretLocation(loc, r.SecretGroup)
+			if !ok {
+				continue
+			}
+			s := content[start:end]
 			entropy := CalculateShannonEntropy(s)
 			if entropy < r.MinEntropy {
 				continue
@@ -29,8 +36,23 @@ func findStaticRuleMatches(content string, rules []StaticRule) (matches []TextMa
 					Value:   s,
 					Entropy: entropy,
 				},
+				FullText: content,
+				StartPos: start,
+				EndPos:   end,
+				match:    content[loc[0]:loc[1]],
 			})
 		}
 	}
 	return
 }
+
+// secretLocation will return the location of the secret in a match of a pattern,
+// which is the capture group given by group, or the full match if group is 0.
+// false is returned if the group is not part of the match or the secret is empty
+func secretLocation(loc []int, group int) (start, end int, ok bool) {
+	if group < 0 || 2*group+1 >= len(loc) {
+		return 0, 0, false
+	}
+	start, end = loc[2*group], loc[2*group+1]
+	return start, end, start >= 0 && end > start
+}